@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/b0bh00d/ls/format"
+	"github.com/b0bh00d/ls/meta"
+	"github.com/b0bh00d/ls/scm"
+	"github.com/b0bh00d/ls/term"
+)
+
+// the narrowest display that has room for the details pane
+const browsePaneMinWidth = 50
+
+// the width of the labels in the details pane
+const browseLabelWidth = 10
+
+const browseHelp = "Enter: open  Backspace: up  Tab: select  Esc: done  Ctrl-C: cancel  (type to filter)"
+
+// browser ... The state of the full-screen browse mode: the folder being browsed,
+// its entries (and the ones that pass the filter), the cursor and the paths
+// that have been selected.
+type browser struct {
+	dir       string
+	patterns  []string
+	entries   []entryData
+	scmStatus scm.Status
+	filter    string
+	visible   []int
+	cursor    int
+	scroll    int
+	bodyRows  int
+	selected  map[string]bool
+	order     []string
+	status    string
+	tty       *os.File
+	out       *bufio.Writer
+}
+
+// browse ... Runs the full-screen browse mode, starting in the directory, and
+// returns the paths the user chose (nothing, if they cancelled).  The browser is
+// drawn on the terminal itself, so that stdout can be captured by the shell.
+func browse(dir string, patterns []string) []string {
+	tty, err := term.OpenTerminal()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	defer tty.Close()
+
+	// colors depend on the terminal being drawn on, not on where stdout goes
+	if mode, _ := term.ParseColorMode(lsConfigData.colorMode); mode == term.COLOR_ALWAYS ||
+		(mode == term.COLOR_AUTO && len(os.Getenv("NO_COLOR")) == 0 && os.Getenv("TERM") != "dumb") {
+		color.NoColor = false
+	}
+
+	b := browser{patterns: patterns, selected: make(map[string]bool), tty: tty, out: bufio.NewWriter(tty)}
+	if err := b.load(dir, ""); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+
+	// switch to the alternate screen (and hide the cursor) for the duration
+	b.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		b.out.WriteString("\x1b[?25h\x1b[?1049l")
+		b.out.Flush()
+	}()
+
+	return b.run()
+}
+
+// load reads the entries of the directory (and its SCM status), placing the
+// cursor on the focused entry if it is there
+func (b *browser) load(dir string, focus string) error {
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+
+	b.dir = dir
+	b.scmStatus = scm.GetScmStatus(dir)
+	dirEntries, fileEntries := treeEntries(b.patterns)
+	b.entries = orderEntries(dirEntries, fileEntries, &b.scmStatus, dir)
+
+	b.filter = ""
+	b.applyFilter()
+	b.cursor = 0
+	b.scroll = 0
+	for i, index := range b.visible {
+		if b.entries[index].file == focus {
+			b.cursor = i
+		}
+	}
+
+	return nil
+}
+
+// applyFilter keeps the entries whose names contain the filter text
+func (b *browser) applyFilter() {
+	b.visible = b.visible[:0]
+	filter := strings.ToLower(b.filter)
+	for i := range b.entries {
+		if strings.Contains(strings.ToLower(b.entries[i].file), filter) {
+			b.visible = append(b.visible, i)
+		}
+	}
+	b.moveTo(b.cursor)
+}
+
+// moveTo places the cursor on the visible entry, keeping it within bounds
+func (b *browser) moveTo(cursor int) {
+	if cursor >= len(b.visible) {
+		cursor = len(b.visible) - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	b.cursor = cursor
+}
+
+// current returns the entry under the cursor, if there is one
+func (b *browser) current() *entryData {
+	if len(b.visible) == 0 {
+		return nil
+	}
+	return &b.entries[b.visible[b.cursor]]
+}
+
+func (b *browser) path(entry *entryData) string {
+	return filepath.Join(b.dir, strings.TrimSuffix(entry.file, "/"))
+}
+
+// toggle selects the entry, or deselects it if it already is
+func (b *browser) toggle(entry *entryData) {
+	path := b.path(entry)
+	if !b.selected[path] {
+		b.selected[path] = true
+		b.order = append(b.order, path)
+		return
+	}
+
+	delete(b.selected, path)
+	for i := range b.order {
+		if b.order[i] == path {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// chosen returns the selected paths (in the order they were selected), or the
+// directory being browsed if nothing was
+func (b *browser) chosen() []string {
+	if len(b.order) != 0 {
+		return b.order
+	}
+	return []string{b.dir}
+}
+
+// run handles keys until the user is done
+func (b *browser) run() []string {
+	for {
+		b.draw()
+
+		key := term.ReadKey()
+		b.status = ""
+
+		switch {
+		case key == term.KEY_ERROR || key == 3:
+			return nil
+
+		case key == 27:
+			if len(b.filter) == 0 {
+				return b.chosen()
+			}
+			b.filter = ""
+			b.applyFilter()
+
+		case key == term.KEY_UP:
+			b.moveTo(b.cursor - 1)
+		case key == term.KEY_DOWN:
+			b.moveTo(b.cursor + 1)
+		case key == term.KEY_PAGE_UP:
+			b.moveTo(b.cursor - b.bodyRows)
+		case key == term.KEY_PAGE_DOWN:
+			b.moveTo(b.cursor + b.bodyRows)
+
+		case key == '\r' || key == '\n':
+			entry := b.current()
+			if entry == nil {
+				continue
+			}
+			if !entry.isDir {
+				if !b.selected[b.path(entry)] {
+					b.toggle(entry)
+				}
+				return b.chosen()
+			}
+			if err := b.load(b.path(entry), ""); err != nil {
+				b.status = err.Error()
+				os.Chdir(b.dir)
+			}
+
+		case key == 8 || key == 127:
+			if len(b.filter) != 0 {
+				filter := []rune(b.filter)
+				b.filter = string(filter[:len(filter)-1])
+				b.applyFilter()
+				continue
+			}
+			if parent := filepath.Dir(b.dir); parent != b.dir {
+				if err := b.load(parent, filepath.Base(b.dir)+"/"); err != nil {
+					b.status = err.Error()
+					os.Chdir(b.dir)
+				}
+			}
+
+		case key == '\t':
+			if entry := b.current(); entry != nil {
+				b.toggle(entry)
+				b.moveTo(b.cursor + 1)
+			}
+
+		case key >= ' ':
+			b.filter += string(rune(key))
+			b.applyFilter()
+			b.moveTo(0)
+		}
+	}
+}
+
+// draw repaints the whole display: the folder, the entries (with the details of
+// the one under the cursor alongside them) and a status line
+func (b *browser) draw() {
+	rows, cols := term.TerminalSize(b.tty)
+	if rows <= 0 || cols <= 0 {
+		rows, cols = termCaps.Height, termCaps.Width
+	}
+
+	// leave the last column of the display alone
+	listWidth := cols - 1
+	paneWidth := 0
+	if cols >= browsePaneMinWidth {
+		listWidth = cols * 3 / 5
+		paneWidth = cols - listWidth - 4
+	}
+
+	b.bodyRows = rows - 2
+	if b.bodyRows < 1 {
+		b.bodyRows = 1
+	}
+	if b.cursor < b.scroll {
+		b.scroll = b.cursor
+	}
+	if b.cursor >= b.scroll+b.bodyRows {
+		b.scroll = b.cursor - b.bodyRows + 1
+	}
+
+	var details []string
+	if paneWidth > 0 {
+		details = b.details(paneWidth)
+	}
+
+	separator := "|"
+	if termCaps.UTF8 {
+		separator = "│"
+	}
+
+	b.out.WriteString("\x1b[H")
+
+	header := " " + keepStart(elide(b.dir, cols-2, lsConfigData.pathElision), cols-2)
+	b.out.WriteString(lsConfigData.coloring["directories"].Sprint(header) + "\x1b[K\r\n")
+
+	for r := 0; r < b.bodyRows; r++ {
+		line := b.listCell(b.scroll+r, listWidth)
+		if paneWidth > 0 {
+			line += " " + separator + " "
+			if r < len(details) {
+				line += details[r]
+			}
+		}
+		b.out.WriteString(line + "\x1b[K\r\n")
+	}
+
+	footer := browseHelp
+	switch {
+	case len(b.status) != 0:
+		footer = b.status
+	case len(b.filter) != 0:
+		footer = fmt.Sprintf("Filter: %s  (%d of %d)", b.filter, len(b.visible), len(b.entries))
+	}
+	if count := len(b.order); count != 0 {
+		footer = fmt.Sprintf("[%d selected]  %s", count, footer)
+	}
+	b.out.WriteString(keepStart(footer, cols-1) + "\x1b[K")
+
+	b.out.Flush()
+}
+
+// listCell renders the visible entry at the index as a cell of the list: a
+// selection mark, its SCM codes (if the folder is managed) and its name
+func (b *browser) listCell(index int, width int) string {
+	if index >= len(b.visible) {
+		return strings.Repeat(" ", width)
+	}
+	entry := &b.entries[b.visible[index]]
+
+	mark := " "
+	if b.selected[b.path(entry)] {
+		mark = "*"
+	}
+
+	codes := ""
+	if len(b.scmStatus.Entries) != 0 || len(b.scmStatus.Deleted) != 0 {
+		if scmEntry, ok := b.scmStatus.Entries[entry.file]; ok {
+			codes = scmEntry.Codes
+		}
+		codes += strings.Repeat(" ", b.scmStatus.MaxWidth-len(codes)) + " "
+	}
+
+	room := width - 1 - len(codes)
+	if room < 0 {
+		room = 0
+	}
+	name := keepStart(elide(entry.file, room, lsConfigData.elision), room)
+	padding := strings.Repeat(" ", room-displayWidth(name))
+
+	// the cursor is drawn in reverse video, which would be lost in the colors
+	if index == b.cursor {
+		return "\x1b[7m" + mark + codes + name + padding + "\x1b[m"
+	}
+
+	if entry.isDir {
+		name = lsConfigData.coloring["directories"].Sprint(name)
+	} else if fileColor := entryColor(entry.file); fileColor != nil {
+		name = fileColor.Sprint(name)
+	}
+	return mark + colorizeCodes(codes) + name + padding
+}
+
+// scmState describes what the SCM has to say about an entry
+func scmState(entry *scm.Entry) string {
+	switch {
+	case entry.Bits&scm.STATUS_RENAMED != 0:
+		return "renamed"
+	case entry.Bits&scm.STATUS_ADDED != 0:
+		return "added"
+	case entry.Bits&scm.STATUS_MODIFIED != 0:
+		return "modified"
+	case entry.Bits&scm.STATUS_DELETED != 0:
+		return "deleted"
+	}
+	return "changed"
+}
+
+// details renders the lines of the details pane for the entry under the
+// cursor: its name, size, timestamps, permissions, SCM status, symlink target
+// and all of its metadata
+func (b *browser) details(width int) []string {
+	entry := b.current()
+	if entry == nil {
+		return []string{"(no entries)"}
+	}
+
+	var lines []string
+	add := func(label string, value string, c *color.Color) {
+		for i, part := range wrapText(value, width-browseLabelWidth) {
+			prefix := strings.Repeat(" ", browseLabelWidth)
+			if i == 0 {
+				prefix = fmt.Sprintf("%-*s", browseLabelWidth, label+":")
+			}
+			if c != nil {
+				part = c.Sprint(part)
+			}
+			lines = append(lines, prefix+part)
+		}
+	}
+
+	nameColor := entryColor(entry.file)
+	if entry.isDir {
+		nameColor = lsConfigData.coloring["directories"]
+	}
+	for _, part := range wrapText(entry.file, width) {
+		if nameColor != nil {
+			part = nameColor.Sprint(part)
+		}
+		lines = append(lines, part)
+	}
+	lines = append(lines, "")
+
+	if entry.isDir {
+		add("Type", "directory", nil)
+	} else {
+		add("Size", strings.TrimSpace(format.Size(entry.size)), nil)
+	}
+
+	times := []struct {
+		label string
+		field string
+	}{
+		{"Modified", TIME_MODIFIED},
+		{"Accessed", TIME_ACCESSED},
+		{"Changed", TIME_CHANGED},
+		{"Created", TIME_BIRTH},
+	}
+	for _, t := range times {
+		if value := strings.TrimSpace(timeFormat.format(entryTime(entry, t.field))); len(value) != 0 {
+			add(t.label, value, nil)
+		}
+	}
+
+	if permissions := strings.TrimSpace(strings.Join([]string{entry.mode, entry.owner, entry.group}, " ")); len(permissions) != 0 {
+		add("Mode", permissions, nil)
+	}
+	add("Flags", entry.stats, nil)
+
+	if scmEntry, ok := b.scmStatus.Entries[entry.file]; ok {
+		add("SCM", scmEntry.Codes+" ("+scmState(scmEntry)+")", nil)
+		if deleted, ok := b.scmStatus.Deleted[entry.file]; ok && len(deleted.Original) != 0 {
+			add("Renamed", "from "+deleted.Original, nil)
+		}
+	}
+
+	if len(entry.symlink) != 0 {
+		add("Target", entry.symlink, lsConfigData.coloring["symlink"])
+	}
+
+	for _, item := range gatherMetadata(*entry, b.dir, true) {
+		if item.source == meta.SOURCE_SYMLINK {
+			continue
+		}
+		lines = append(lines, "", item.source+":")
+		for _, part := range wrapText(item.text, width) {
+			lines = append(lines, lsConfigData.coloring[item.colorKey()].Sprint(part))
+		}
+	}
+
+	return lines
+}
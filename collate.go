@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// compareNames ... The collation used to order entry names.  It is selected by the
+// "collation" setting; see newCollator().
+var compareNames = compareNatural
+
+// compares strings by their bytes (i.e., the order filepath.Glob returns them)
+func compareOrdinal(a string, b string) int {
+	return compareStrings(a, b)
+}
+
+// compares strings ignoring case, falling back to their bytes to break ties
+func compareNoCase(a string, b string) int {
+	result := compareStrings(strings.ToLower(a), strings.ToLower(b))
+	if result == 0 {
+		result = compareStrings(a, b)
+	}
+	return result
+}
+
+// returns the run of digits at the start of the string, minus any leading zeros
+func digitRun(s string) (string, int) {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return strings.TrimLeft(s[:end], "0"), end
+}
+
+// compares strings the way Windows Explorer does: case-insensitively, with runs
+// of digits compared by their numeric value (so "file2" comes before "file10")
+func compareNatural(a string, b string) int {
+	x, y := a, b
+	for len(x) != 0 && len(y) != 0 {
+		if x[0] >= '0' && x[0] <= '9' && y[0] >= '0' && y[0] <= '9' {
+			xDigits, xLen := digitRun(x)
+			yDigits, yLen := digitRun(y)
+
+			// without leading zeros, the longer run is the larger number
+			if len(xDigits) != len(yDigits) {
+				return sign(len(xDigits) - len(yDigits))
+			}
+			if result := compareStrings(xDigits, yDigits); result != 0 {
+				return result
+			}
+
+			x, y = x[xLen:], y[yLen:]
+			continue
+		}
+
+		xr, xSize := utf8.DecodeRuneInString(x)
+		yr, ySize := utf8.DecodeRuneInString(y)
+		if result := sign(int(unicode.ToLower(xr)) - int(unicode.ToLower(yr))); result != 0 {
+			return result
+		}
+
+		x, y = x[xSize:], y[ySize:]
+	}
+
+	if result := sign(len(x) - len(y)); result != 0 {
+		return result
+	}
+
+	return compareNoCase(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// the locale for Unicode collation, taken from the environment if it hasn't
+// been configured
+func collationLocale(locale string) language.Tag {
+	return localeTag(locale, "LC_COLLATE")
+}
+
+// newCollator ... Returns the comparison function for the named collation:
+//
+//	ordinal  byte order (what filepath.Glob returns)
+//	nocase   case-insensitive
+//	natural  case-insensitive, with numbers compared by value (Windows Explorer)
+//	unicode  Unicode collation for the configured (or environment's) locale, so
+//	         accented names sort where a native speaker expects them
+func newCollator(name string, locale string) (func(a string, b string) int, error) {
+	switch strings.ToLower(name) {
+	case "ordinal":
+		return compareOrdinal, nil
+	case "nocase":
+		return compareNoCase, nil
+	case "", "natural":
+		return compareNatural, nil
+	case "unicode":
+		c := collate.New(collationLocale(locale), collate.IgnoreCase, collate.Numeric)
+		return func(a string, b string) int {
+			result := c.CompareString(a, b)
+			if result == 0 {
+				result = compareStrings(a, b)
+			}
+			return result
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown collation '%s' (expected ordinal, nocase, natural or unicode)", name)
+}
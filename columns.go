@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/b0bh00d/ls/format"
+	"github.com/b0bh00d/ls/scm"
+)
+
+// everything a column might need to know about the folder being listed
+type columnContext struct {
+	cwd            string
+	scmStatus      *scm.Status
+	bytesPerSector uint64
+	names          []string
+	authors        map[string]string
+}
+
+// listColumn ... A single field of the long listing.  cell renders the field for
+// an entry (uncolored); the "name" and "meta" columns are rendered by the
+// listing itself, since they fill whatever room the other columns leave.
+type listColumn struct {
+	name       string
+	rightAlign bool
+	cell       func(entry *entryData, ctx *columnContext) string
+}
+
+func scmCell(entry *entryData, ctx *columnContext) string {
+	if scmEntry, ok := ctx.scmStatus.Entries[entry.file]; ok {
+		return scmEntry.Codes
+	}
+	return ""
+}
+
+func timeCell(field string) func(entry *entryData, ctx *columnContext) string {
+	return func(entry *entryData, ctx *columnContext) string {
+		which := field
+		if len(which) == 0 {
+			which = lsConfigData.timeField
+		}
+		return timeFormat.format(entryTime(entry, which))
+	}
+}
+
+func sizeCell(entry *entryData, ctx *columnContext) string {
+	if entry.isDir && entry.usage == nil {
+		return ""
+	}
+	return format.Size(entry.size)
+}
+
+func allocatedCell(entry *entryData, ctx *columnContext) string {
+	if entry.isDir && entry.usage == nil {
+		return ""
+	}
+	allocated := clusterBytes(entry.size, ctx.bytesPerSector)
+	if entry.usage != nil {
+		allocated = entry.usage.allocated
+	}
+	if ctx.bytesPerSector == 0 {
+		return ""
+	}
+	return format.Size(allocated)
+}
+
+func countCell(entry *entryData, ctx *columnContext) string {
+	if entry.usage == nil {
+		return ""
+	}
+	return format.Unsigned(entry.usage.files)
+}
+
+func flagsCell(entry *entryData, ctx *columnContext) string {
+	return entry.stats
+}
+
+func modeCell(entry *entryData, ctx *columnContext) string {
+	if lsConfigData.hidePermissions {
+		return ""
+	}
+	return entry.mode
+}
+
+func ownerCell(entry *entryData, ctx *columnContext) string {
+	if lsConfigData.hideOwner {
+		return ""
+	}
+	return entry.owner
+}
+
+func groupCell(entry *entryData, ctx *columnContext) string {
+	if lsConfigData.hideGroup {
+		return ""
+	}
+	return entry.group
+}
+
+func linesCell(entry *entryData, ctx *columnContext) string {
+	if entry.isDir {
+		return ""
+	}
+	if count := lineCount(entry.file); count >= 0 {
+		return format.Integer(int64(count))
+	}
+	return ""
+}
+
+// hashCell renders the hash of a file with the algorithm, or with
+// lsConfigData.hashAlgorithm if there isn't one, abbreviated to
+// lsConfigData.hashDigits (unless that's 0)
+func hashCell(algorithm string) func(entry *entryData, ctx *columnContext) string {
+	return func(entry *entryData, ctx *columnContext) string {
+		which := algorithm
+		if len(which) == 0 {
+			which = lsConfigData.hashAlgorithm
+		}
+		digest := entryHash(entry, which)
+		if digits := lsConfigData.hashDigits; digits > 0 && len(digest) > digits {
+			digest = digest[:digits]
+		}
+		return digest
+	}
+}
+
+func authorCell(entry *entryData, ctx *columnContext) string {
+	if ctx.authors == nil {
+		ctx.authors = scm.LastAuthors(ctx.scmStatus.Manager, ctx.names)
+	}
+	return ctx.authors[entry.file]
+}
+
+var listColumns = map[string]listColumn{
+	"scm":    {name: "scm", cell: scmCell},
+	"time":   {name: "time", cell: timeCell("")},
+	"mtime":  {name: "mtime", cell: timeCell(TIME_MODIFIED)},
+	"atime":  {name: "atime", cell: timeCell(TIME_ACCESSED)},
+	"ctime":  {name: "ctime", cell: timeCell(TIME_CHANGED)},
+	"btime":  {name: "btime", cell: timeCell(TIME_BIRTH)},
+	"size":   {name: "size", rightAlign: true, cell: sizeCell},
+	"alloc":  {name: "alloc", rightAlign: true, cell: allocatedCell},
+	"count":  {name: "count", rightAlign: true, cell: countCell},
+	"flags":  {name: "flags", cell: flagsCell},
+	"mode":   {name: "mode", cell: modeCell},
+	"owner":  {name: "owner", cell: ownerCell},
+	"group":  {name: "group", cell: groupCell},
+	"lines":  {name: "lines", rightAlign: true, cell: linesCell},
+	"hash":   {name: "hash", cell: hashCell("")},
+	"sha256": {name: "sha256", cell: hashCell(HASH_SHA256)},
+	"sha1":   {name: "sha1", cell: hashCell(HASH_SHA1)},
+	"md5":    {name: "md5", cell: hashCell(HASH_MD5)},
+	"crc32":  {name: "crc32", cell: hashCell(HASH_CRC32)},
+	"fnv":    {name: "fnv", cell: hashCell(HASH_FNV)},
+	"author": {name: "author", cell: authorCell},
+	"diff":   {name: "diff", cell: diffCell},
+	"name":   {name: "name"},
+	"meta":   {name: "meta"},
+}
+
+var columnAliases = map[string]string{
+	"status":      "scm",
+	"attributes":  "flags",
+	"permissions": "mode",
+	"user":        "owner",
+	"allocated":   "alloc",
+	"files":       "count",
+	"description": "meta",
+	"metadata":    "meta",
+}
+
+// hashColumns returns the algorithms of the hash columns among the columns
+func hashColumns(columns []listColumn) []string {
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		algorithm := column.name
+		if algorithm == "hash" {
+			algorithm = lsConfigData.hashAlgorithm
+		}
+		if _, ok := hashAlgorithms[algorithm]; ok && !seen[algorithm] {
+			seen[algorithm] = true
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// defaultColumns returns the layout ls has always used, with the configured
+// timestamp column(s)
+func defaultColumns() []listColumn {
+	spec := "scm," + strings.Join(lsConfigData.timeColumns, ",") + ",size,flags,mode,owner,group,name,meta"
+	columns, _ := parseColumns(spec)
+	return columns
+}
+
+// parseColumns ... Converts a column specification like "scm,mtime,size,name,meta"
+// into the columns of the long listing.  The name column is required, and the
+// metadata column (if present) has to be the last one.
+func parseColumns(spec string) ([]listColumn, error) {
+	var columns []listColumn
+	hasName := false
+
+	for _, item := range strings.Split(spec, ",") {
+		name := strings.ToLower(strings.TrimSpace(item))
+		if len(name) == 0 {
+			continue
+		}
+		if alias, ok := columnAliases[name]; ok {
+			name = alias
+		}
+
+		column, ok := listColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column '%s'", item)
+		}
+		if len(columns) != 0 && columns[len(columns)-1].name == "meta" {
+			return nil, fmt.Errorf("the meta column has to be the last column")
+		}
+		if name == "name" {
+			if hasName {
+				return nil, fmt.Errorf("the name column can only appear once")
+			}
+			hasName = true
+		}
+
+		columns = append(columns, column)
+	}
+
+	if !hasName {
+		return nil, fmt.Errorf("the columns have to include the name column")
+	}
+
+	return columns, nil
+}
+
+// listingLayout ... The cells of a long listing, and the widths of its columns,
+// which are measured from the entries being listed.  Columns that are empty for
+// every entry (e.g., owners on Windows) are left out.
+type listingLayout struct {
+	columns   []listColumn
+	cells     [][]string
+	widths    []int
+	nameWidth int
+	ctx       columnContext
+}
+
+func newListingLayout(entries []entryData, cwd string, scmStatus *scm.Status, bytesPerSector uint64) *listingLayout {
+	layout := listingLayout{columns: lsConfigData.columns, ctx: columnContext{cwd: cwd, scmStatus: scmStatus, bytesPerSector: bytesPerSector}}
+	for i := range entries {
+		layout.ctx.names = append(layout.ctx.names, entries[i].file)
+	}
+
+	// the files are hashed all at once, rather than one cell at a time
+	if algorithms := hashColumns(layout.columns); len(algorithms) != 0 {
+		hashFiles(entries, algorithms)
+	}
+
+	layout.widths = make([]int, len(layout.columns))
+	layout.cells = make([][]string, len(entries))
+	for i := range entries {
+		layout.cells[i] = make([]string, len(layout.columns))
+		for j, column := range layout.columns {
+			if column.cell == nil {
+				continue
+			}
+			cell := column.cell(&entries[i], &layout.ctx)
+			layout.cells[i][j] = cell
+			if width := displayWidth(cell); width > layout.widths[j] {
+				layout.widths[j] = width
+			}
+		}
+	}
+
+	// the codes column is kept in a managed folder even if none of the listed
+	// entries have codes, so that deleted entries line up with the rest
+	if len(scmStatus.Entries) != 0 || len(scmStatus.Deleted) != 0 {
+		for j, column := range layout.columns {
+			if column.name == "scm" && scmStatus.MaxWidth > layout.widths[j] {
+				layout.widths[j] = scmStatus.MaxWidth
+			}
+		}
+	}
+
+	// likewise the markers of a comparison, so that every folder's names line up
+	if diffing != nil {
+		for j, column := range layout.columns {
+			if column.name == "diff" && layout.widths[j] == 0 {
+				layout.widths[j] = 1
+			}
+		}
+	}
+
+	layout.nameWidth = layout.measureNames()
+
+	return &layout
+}
+
+// measureNames returns the width names are padded to when columns follow them:
+// that of the longest name, within whatever room the other columns leave
+func (l *listingLayout) measureNames() int {
+	width := 0
+	for _, name := range l.ctx.names {
+		if w := displayWidth(name); w > width {
+			width = w
+		}
+	}
+
+	after := 0
+	for j := len(l.columns) - 1; j >= 0 && l.columns[j].name != "name"; j-- {
+		if l.columns[j].cell != nil && l.widths[j] != 0 {
+			after += l.widths[j] + 1
+		}
+	}
+
+	// leave the last column of the display alone.  If the other columns don't
+	// leave any room, the line wraps anyway, so the names are left whole.
+	if room := termCaps.Width - l.nameOffset() - after - 1; room > 0 && width > room {
+		width = room
+	}
+	return width
+}
+
+// pad aligns the cell within its column
+func (l *listingLayout) pad(j int, cell string) string {
+	padding := strings.Repeat(" ", l.widths[j]-displayWidth(cell))
+	if l.columns[j].rightAlign {
+		return padding + cell
+	}
+	return cell + padding
+}
+
+// leaderWidth ... Returns the width of the columns between the SCM codes and the
+// name, which the separator above deleted entries spans.
+func (l *listingLayout) leaderWidth() int {
+	width := 0
+	for j, column := range l.columns {
+		if column.name == "name" {
+			break
+		}
+		if column.cell == nil || column.name == "scm" || l.widths[j] == 0 {
+			continue
+		}
+		width += l.widths[j] + 1
+	}
+	return width - 1
+}
+
+// nameOffset ... Returns the column the names of the listing start at.
+func (l *listingLayout) nameOffset() int {
+	offset := 0
+	for j, column := range l.columns {
+		if column.name == "name" {
+			break
+		}
+		if column.cell == nil || l.widths[j] == 0 {
+			continue
+		}
+		offset += l.widths[j] + 1
+	}
+	return offset
+}
+
+// row ... Renders the entry at the index as a line of the listing, followed by any
+// continuation lines its metadata needs.
+func (l *listingLayout) row(index int, entry entryData) []string {
+	var textColor func(a ...interface{}) string
+	if entry.isDir {
+		textColor = lsConfigData.coloring["directories"].Sprint
+	} else if fileColor := entryColor(entry.file); fileColor != nil {
+		textColor = fileColor.Sprint
+	} else {
+		textColor = fmt.Sprint
+	}
+	if highlight := changeHighlight(entry.file); len(highlight) != 0 {
+		textColor = lsConfigData.coloring[highlight].Sprint
+	}
+
+	line := ""
+	run := ""
+	used := 0
+	nameColumn := 0
+
+	// the SCM codes have colors of their own; everything else is drawn in the
+	// entry's color
+	flush := func() {
+		if len(run) != 0 {
+			line += textColor(run)
+			run = ""
+		}
+	}
+
+	for j, column := range l.columns {
+		switch {
+		case column.name == "name":
+			nameColumn = used
+			_, scmRename := scmColumn(entry, l.ctx.scmStatus)
+			var name string
+			if j < len(l.columns)-1 && l.columns[j+1].name != "meta" {
+				name = elideName(entry.file, scmRename, l.nameWidth)
+				if w := displayWidth(name); w < l.nameWidth {
+					name += strings.Repeat(" ", l.nameWidth-w)
+				}
+			} else {
+				name = elideName(entry.file, scmRename, l.nameWidth)
+			}
+			run += name + " "
+			used += displayWidth(name) + 1
+
+		case column.name == "meta":
+			// continuation lines are indented a little past the start of the name,
+			// unless that leaves them too little room
+			indent := nameColumn + 2
+			if indent > termCaps.Width/2 {
+				indent = termCaps.Width / 2
+			}
+			metadata, rendered, continued := placeMetadata(entryMetadata(entry, l.ctx.cwd), termCaps.Width-used-4, strings.Repeat(" ", indent))
+			if metaDataLength := displayWidth(metadata); metaDataLength != 0 {
+				run += strings.Repeat("-", termCaps.Width-used-metaDataLength-3) + "> "
+			} else {
+				run = strings.TrimRight(run, " ")
+			}
+			flush()
+			return append([]string{line + rendered}, continued...)
+
+		case l.widths[j] == 0:
+
+		case column.name == "scm":
+			flush()
+			line += colorizeCodes(l.pad(j, l.cells[index][j])) + " "
+			used += l.widths[j] + 1
+
+		default:
+			run += l.pad(j, l.cells[index][j]) + " "
+			used += l.widths[j] + 1
+		}
+	}
+
+	run = strings.TrimRight(run, " ")
+	flush()
+	return []string{line}
+}
@@ -5,38 +5,139 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/spf13/viper"
+
+	"github.com/b0bh00d/ls/meta"
 )
 
 type configData struct {
-	fileFirst      bool
-	hideHidden     bool
-	hideSystem     bool
-	hideLinks      bool
-	hideMetaData   bool
-	compactSizes   bool
-	elideLongNames bool
-	autoMore       bool
-	sortAscending  bool
-	sortDescending bool
-	coloring       map[string]*color.Color
+	fileFirst        bool
+	hideHidden       bool
+	hideSystem       bool
+	hideLinks        bool
+	hideMetaData     bool
+	hidePermissions  bool
+	hideOwner        bool
+	hideGroup        bool
+	compactSizes     bool
+	sizeUnits        string
+	blockSize        string
+	sizePrecision    int
+	numberLocale     string
+	groupSeparator   *string
+	decimalSeparator *string
+	xattrNames       []string
+	elideLongNames   bool
+	elision          string
+	pathElision      string
+	ellipsis         string
+	metaOverflow     string
+	metaSourcesSpec  string
+	metaSources      []string
+	metaDisplay      string
+	autoMore         bool
+	pager            string
+	colorMode        string
+	recursive        bool
+	maxDepth         int
+	followLinks      bool
+	treeMode         bool
+	treeASCII        bool
+	wideMode         bool
+	browseMode       bool
+	watchMode        bool
+	watchHighlight   int
+	snapshotFile     string
+	diffFile         string
+	snapshotHashes   bool
+	wideAcross       bool
+	timeField        string
+	timeColumnsSpec  string
+	timeColumns      []string
+	timeStyle        string
+	timeZone         string
+	timePrecision    int
+	timeLocale       string
+	dirSizes         bool
+	summaryOnly      bool
+	dirSizeTimeout   string
+	dirSizeWorkers   int
+	hashAlgorithm    string
+	hashWorkers      int
+	hashDigits       int
+	hashCache        bool
+	columnsSpec      string
+	columns          []listColumn
+	sortSpec         string
+	sortKeys         []sortKey
+	groupDirectories bool
+	collation        string
+	collationLocale  string
+	coloring         map[string]*color.Color
 }
 
 var lsConfigData configData = configData{
-	fileFirst:      false,
-	hideHidden:     false,
-	hideSystem:     false,
-	hideLinks:      false,
-	hideMetaData:   false,
-	compactSizes:   true,
-	elideLongNames: true,
-	autoMore:       true,
-	sortAscending:  false,
-	sortDescending: false,
-	coloring:       make(map[string]*color.Color),
+	fileFirst:        false,
+	hideHidden:       false,
+	hideSystem:       false,
+	hideLinks:        false,
+	hideMetaData:     false,
+	hidePermissions:  false,
+	hideOwner:        false,
+	hideGroup:        false,
+	compactSizes:     true,
+	sizeUnits:        "iec",
+	blockSize:        "",
+	sizePrecision:    2,
+	numberLocale:     "",
+	xattrNames:       meta.XattrNames,
+	elideLongNames:   true,
+	elision:          ELIDE_MIDDLE,
+	pathElision:      ELIDE_PATH,
+	ellipsis:         "...",
+	metaOverflow:     META_TRUNCATE,
+	metaSourcesSpec:  strings.Join(meta.Sources, ","),
+	metaDisplay:      META_FIRST,
+	autoMore:         true,
+	pager:            PAGER_BUILTIN,
+	colorMode:        "auto",
+	recursive:        false,
+	maxDepth:         0,
+	followLinks:      false,
+	treeMode:         false,
+	treeASCII:        false,
+	wideMode:         false,
+	browseMode:       false,
+	watchMode:        false,
+	watchHighlight:   10,
+	snapshotFile:     "",
+	diffFile:         "",
+	snapshotHashes:   false,
+	wideAcross:       false,
+	timeField:        TIME_MODIFIED,
+	timeColumnsSpec:  "",
+	timeStyle:        "classic",
+	timeZone:         "",
+	timePrecision:    0,
+	timeLocale:       "",
+	dirSizes:         false,
+	summaryOnly:      false,
+	dirSizeTimeout:   "",
+	dirSizeWorkers:   0,
+	hashAlgorithm:    HASH_SHA256,
+	hashWorkers:      0,
+	hashDigits:       16,
+	hashCache:        true,
+	columnsSpec:      "",
+	sortSpec:         "",
+	groupDirectories: false,
+	collation:        "natural",
+	collationLocale:  "",
+	coloring:         make(map[string]*color.Color),
 }
 
 type configItems struct {
@@ -115,12 +216,19 @@ func constructColor(fore string, back string, bold bool) *color.Color {
 }
 
 func loadConfig() {
-	appdata := os.Getenv("APPDATA")
-	configFile := fmt.Sprintf("%s\\ls.json", appdata)
+	// %APPDATA% on Windows, $XDG_CONFIG_HOME (or ~/.config) elsewhere
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	configFile := filepath.Join(configDir, "ls.json")
 
 	lsConfigData.coloring["description"] = constructColor("yellow", "", false)
 	lsConfigData.coloring["symlink"] = constructColor("cyan", "", true)
 	lsConfigData.coloring["directories"] = constructColor("magenta", "", true)
+	lsConfigData.coloring["watch.added"] = constructColor("green", "", true)
+	lsConfigData.coloring["watch.modified"] = constructColor("cyan", "", true)
+	lsConfigData.coloring["watch.removed"] = constructColor("red", "", true)
 
 	if _, err := os.Stat(configFile); err == nil {
 		// read in the config (JSON)
@@ -161,18 +269,188 @@ func loadConfig() {
 			lsConfigData.hideMetaData = viper.Get("format.hideMetaData").(bool)
 		}
 
+		if viper.IsSet("format.hidePermissions") {
+			lsConfigData.hidePermissions = viper.Get("format.hidePermissions").(bool)
+		}
+
+		if viper.IsSet("format.hideOwner") {
+			lsConfigData.hideOwner = viper.Get("format.hideOwner").(bool)
+		}
+
+		if viper.IsSet("format.hideGroup") {
+			lsConfigData.hideGroup = viper.Get("format.hideGroup").(bool)
+		}
+
 		if viper.IsSet("format.compactSizes") {
 			lsConfigData.compactSizes = viper.Get("format.compactSizes").(bool)
 		}
 
+		if viper.IsSet("format.sizeUnits") {
+			lsConfigData.sizeUnits = viper.Get("format.sizeUnits").(string)
+		}
+
+		if viper.IsSet("format.blockSize") {
+			lsConfigData.blockSize = viper.Get("format.blockSize").(string)
+		}
+
+		if viper.IsSet("format.sizePrecision") {
+			lsConfigData.sizePrecision = viper.GetInt("format.sizePrecision")
+		}
+
+		if viper.IsSet("format.numberLocale") {
+			lsConfigData.numberLocale = viper.Get("format.numberLocale").(string)
+		}
+
+		// the separators override the locale's, so they're only set if given
+		if viper.IsSet("format.groupSeparator") {
+			separator := viper.Get("format.groupSeparator").(string)
+			lsConfigData.groupSeparator = &separator
+		}
+
+		if viper.IsSet("format.decimalSeparator") {
+			separator := viper.Get("format.decimalSeparator").(string)
+			lsConfigData.decimalSeparator = &separator
+		}
+
 		if viper.IsSet("format.elideLongNames") {
 			lsConfigData.elideLongNames = viper.Get("format.elideLongNames").(bool)
 		}
 
+		if viper.IsSet("format.elision") {
+			lsConfigData.elision = viper.Get("format.elision").(string)
+		}
+
+		if viper.IsSet("format.pathElision") {
+			lsConfigData.pathElision = viper.Get("format.pathElision").(string)
+		}
+
+		if viper.IsSet("format.ellipsis") {
+			lsConfigData.ellipsis = viper.Get("format.ellipsis").(string)
+		}
+
+		if viper.IsSet("format.metaOverflow") {
+			lsConfigData.metaOverflow = viper.Get("format.metaOverflow").(string)
+		}
+
 		if viper.IsSet("format.autoMore") {
 			lsConfigData.autoMore = viper.Get("format.autoMore").(bool)
 		}
 
+		if viper.IsSet("format.pager") {
+			lsConfigData.pager = viper.Get("format.pager").(string)
+		}
+
+		if viper.IsSet("meta.xattrNames") {
+			names := viper.Get("meta.xattrNames").(string)
+			lsConfigData.xattrNames = nil
+			for _, name := range strings.Split(names, ";") {
+				name = strings.TrimSpace(name)
+				if len(name) != 0 {
+					lsConfigData.xattrNames = append(lsConfigData.xattrNames, name)
+				}
+			}
+		}
+
+		if viper.IsSet("meta.sources") {
+			lsConfigData.metaSourcesSpec = viper.Get("meta.sources").(string)
+		}
+
+		if viper.IsSet("meta.display") {
+			lsConfigData.metaDisplay = viper.Get("meta.display").(string)
+		}
+
+		if viper.IsSet("format.followLinks") {
+			lsConfigData.followLinks = viper.Get("format.followLinks").(bool)
+		}
+
+		if viper.IsSet("format.treeASCII") {
+			lsConfigData.treeASCII = viper.Get("format.treeASCII").(bool)
+		}
+
+		if viper.IsSet("format.wideAcross") {
+			lsConfigData.wideAcross = viper.Get("format.wideAcross").(bool)
+		}
+
+		if viper.IsSet("format.timeField") {
+			lsConfigData.timeField = viper.Get("format.timeField").(string)
+		}
+
+		if viper.IsSet("format.timeColumns") {
+			lsConfigData.timeColumnsSpec = viper.Get("format.timeColumns").(string)
+		}
+
+		if viper.IsSet("format.timeFormat") {
+			lsConfigData.timeStyle = viper.Get("format.timeFormat").(string)
+		}
+
+		if viper.IsSet("format.timeZone") {
+			lsConfigData.timeZone = viper.Get("format.timeZone").(string)
+		}
+
+		if viper.IsSet("format.timePrecision") {
+			lsConfigData.timePrecision = viper.GetInt("format.timePrecision")
+		}
+
+		if viper.IsSet("format.timeLocale") {
+			lsConfigData.timeLocale = viper.Get("format.timeLocale").(string)
+		}
+
+		if viper.IsSet("format.dirSizes") {
+			lsConfigData.dirSizes = viper.Get("format.dirSizes").(bool)
+		}
+
+		if viper.IsSet("format.dirSizeTimeout") {
+			lsConfigData.dirSizeTimeout = viper.Get("format.dirSizeTimeout").(string)
+		}
+
+		if viper.IsSet("format.dirSizeWorkers") {
+			lsConfigData.dirSizeWorkers = viper.GetInt("format.dirSizeWorkers")
+		}
+
+		if viper.IsSet("format.hash") {
+			lsConfigData.hashAlgorithm = viper.Get("format.hash").(string)
+		}
+
+		if viper.IsSet("format.hashWorkers") {
+			lsConfigData.hashWorkers = viper.GetInt("format.hashWorkers")
+		}
+
+		if viper.IsSet("format.hashDigits") {
+			lsConfigData.hashDigits = viper.GetInt("format.hashDigits")
+		}
+
+		if viper.IsSet("format.hashCache") {
+			lsConfigData.hashCache = viper.Get("format.hashCache").(bool)
+		}
+
+		if viper.IsSet("format.watchHighlight") {
+			lsConfigData.watchHighlight = viper.GetInt("format.watchHighlight")
+		}
+
+		if viper.IsSet("format.columns") {
+			lsConfigData.columnsSpec = viper.Get("format.columns").(string)
+		}
+
+		if viper.IsSet("format.sort") {
+			lsConfigData.sortSpec = viper.Get("format.sort").(string)
+		}
+
+		if viper.IsSet("format.groupDirectories") {
+			lsConfigData.groupDirectories = viper.Get("format.groupDirectories").(bool)
+		}
+
+		if viper.IsSet("format.collation") {
+			lsConfigData.collation = viper.Get("format.collation").(string)
+		}
+
+		if viper.IsSet("format.collationLocale") {
+			lsConfigData.collationLocale = viper.Get("format.collationLocale").(string)
+		}
+
+		if viper.IsSet("format.color") {
+			lsConfigData.colorMode = viper.Get("format.color").(string)
+		}
+
 		if viper.IsSet("color.description") {
 			biuldColor("description", "")
 		}
@@ -185,6 +463,20 @@ func loadConfig() {
 			biuldColor("directories", "")
 		}
 
+		for _, change := range []string{WATCH_ADDED, WATCH_MODIFIED, WATCH_REMOVED} {
+			if viper.IsSet("color.watch." + change) {
+				biuldColor("watch."+change, "")
+			}
+		}
+
+		// each metadata source can have a color of its own (symlink targets
+		// already do)
+		for _, source := range meta.Sources {
+			if source != meta.SOURCE_SYMLINK && viper.IsSet("color.meta."+source) {
+				biuldColor("meta."+source, "")
+			}
+		}
+
 		if viper.IsSet("color.scm.D") {
 			biuldColor("scm.D", "D")
 		}
@@ -220,8 +512,49 @@ func saveConfig(config configData) error {
 	viper.Set("format.hideSystem", lsConfigData.hideSystem)
 	viper.Set("format.hideLinks", lsConfigData.hideLinks)
 	viper.Set("format.hideMetaData", lsConfigData.hideMetaData)
+	viper.Set("format.hidePermissions", lsConfigData.hidePermissions)
+	viper.Set("format.hideOwner", lsConfigData.hideOwner)
+	viper.Set("format.hideGroup", lsConfigData.hideGroup)
 	viper.Set("format.compactSizes", lsConfigData.compactSizes)
+	viper.Set("format.sizeUnits", lsConfigData.sizeUnits)
+	viper.Set("format.blockSize", lsConfigData.blockSize)
+	viper.Set("format.sizePrecision", lsConfigData.sizePrecision)
+	viper.Set("format.numberLocale", lsConfigData.numberLocale)
+	if lsConfigData.groupSeparator != nil {
+		viper.Set("format.groupSeparator", *lsConfigData.groupSeparator)
+	}
+	if lsConfigData.decimalSeparator != nil {
+		viper.Set("format.decimalSeparator", *lsConfigData.decimalSeparator)
+	}
+	viper.Set("format.elision", lsConfigData.elision)
+	viper.Set("format.pathElision", lsConfigData.pathElision)
+	viper.Set("format.ellipsis", lsConfigData.ellipsis)
+	viper.Set("format.metaOverflow", lsConfigData.metaOverflow)
 	viper.Set("format.autoMore", lsConfigData.autoMore)
+	viper.Set("format.pager", lsConfigData.pager)
+	viper.Set("format.color", lsConfigData.colorMode)
+	viper.Set("format.followLinks", lsConfigData.followLinks)
+	viper.Set("format.treeASCII", lsConfigData.treeASCII)
+	viper.Set("format.wideAcross", lsConfigData.wideAcross)
+	viper.Set("format.timeField", lsConfigData.timeField)
+	viper.Set("format.timeColumns", lsConfigData.timeColumnsSpec)
+	viper.Set("format.timeFormat", lsConfigData.timeStyle)
+	viper.Set("format.timeZone", lsConfigData.timeZone)
+	viper.Set("format.timePrecision", lsConfigData.timePrecision)
+	viper.Set("format.timeLocale", lsConfigData.timeLocale)
+	viper.Set("format.dirSizes", lsConfigData.dirSizes)
+	viper.Set("format.dirSizeTimeout", lsConfigData.dirSizeTimeout)
+	viper.Set("format.dirSizeWorkers", lsConfigData.dirSizeWorkers)
+	viper.Set("format.hash", lsConfigData.hashAlgorithm)
+	viper.Set("format.hashWorkers", lsConfigData.hashWorkers)
+	viper.Set("format.hashDigits", lsConfigData.hashDigits)
+	viper.Set("format.hashCache", lsConfigData.hashCache)
+	viper.Set("format.watchHighlight", lsConfigData.watchHighlight)
+	viper.Set("format.columns", lsConfigData.columnsSpec)
+	viper.Set("format.sort", lsConfigData.sortSpec)
+	viper.Set("format.groupDirectories", lsConfigData.groupDirectories)
+	viper.Set("format.collation", lsConfigData.collation)
+	viper.Set("format.collationLocale", lsConfigData.collationLocale)
 
 	return viper.WriteConfig()
 }
@@ -233,8 +566,46 @@ func parseCommandLine() {
 	flagHideLinks := flag.Bool("L", lsConfigData.hideLinks, "Hide symlink targets")
 	flagHideMetaData := flag.Bool("D", lsConfigData.hideMetaData, "Hide entry metadata")
 	flagExpandSizes := flag.Bool("x", !lsConfigData.compactSizes, "Expand file sizes")
-	flagSortAscending := flag.Bool("m", lsConfigData.hideMetaData, "Sort by ascending modification")
-	flagSortDescending := flag.Bool("M", lsConfigData.hideMetaData, "Sort by descending modification")
+	flagSI := flag.Bool("si", false, "Display sizes in powers of 1000 (kB, MB, ...) instead of 1024 (KiB, MiB, ...)")
+	flagBlockSize := flag.String("block-size", lsConfigData.blockSize, "Display all sizes in this unit (e.g., K, M, GiB or MB)")
+	flagSizePrecision := flag.Int("precision", lsConfigData.sizePrecision, "Number of decimal places of sizes")
+	flagTimeStyle := flag.String("timefmt", lsConfigData.timeStyle, "Timestamp format: classic, iso, long-iso, full-iso, locale, relative or a Go layout")
+	flagTimeZone := flag.String("tz", lsConfigData.timeZone, "Display timestamps in this time zone: local, UTC or a name like Europe/Berlin")
+	flagTimePrecision := flag.Int("subsec", lsConfigData.timePrecision, "Display this many digits of sub-second precision (0 to 9)")
+	flagDirSizes := flag.Bool("dirsize", lsConfigData.dirSizes, "Compute the recursive size of each directory")
+	flagSummaryOnly := flag.Bool("s", lsConfigData.summaryOnly, "Display only the total size of each argument (like du -s)")
+	flagDirSizeTimeout := flag.String("timeout", lsConfigData.dirSizeTimeout, "Stop computing directory sizes after this long (e.g., 10s)")
+	flagElision := flag.String("elide", lsConfigData.elision, "Shorten long names by: middle, end, start, extension or path")
+	flagEllipsis := flag.String("ellipsis", lsConfigData.ellipsis, "The marker left where long text has been shortened (e.g., ... or …)")
+	flagMetaOverflow := flag.String("overflow", lsConfigData.metaOverflow, "Metadata that doesn't fit is: truncate, wrap or drop")
+	flagMetaSources := flag.String("sources", lsConfigData.metaSourcesSpec, "Comma-separated metadata sources, in order of priority (opus, xattr, descript, symlink)")
+	flagMetaDisplay := flag.String("meta", lsConfigData.metaDisplay, "Display metadata from: first (the first source that has any), all (joined) or lines (one per source)")
+	flagColumns := flag.String("columns", lsConfigData.columnsSpec, "Comma-separated columns of the long listing\n(scm, time, mtime, atime, ctime, btime, size, alloc, count, flags, mode, owner, group, lines, hash, sha256, sha1, md5, crc32, fnv, author, diff, name, meta)")
+	flagHashAlgorithm := flag.String("hash", lsConfigData.hashAlgorithm, "Algorithm of the hash column: sha256, sha1, md5, crc32 or fnv")
+	flagHashDigits := flag.Int("hash-digits", lsConfigData.hashDigits, "Hex digits of each hash shown in the listing (0 shows them in full)")
+	flagSortAscending := flag.Bool("m", false, "Sort by ascending timestamp (same as -sort=time)")
+	flagSortDescending := flag.Bool("M", false, "Sort by descending timestamp (same as -sort=time:desc)")
+	flagSort := flag.String("sort", lsConfigData.sortSpec, "Sort by one or more comma-separated keys, each optionally followed by :asc or :desc\n(name, ext, size, time, mtime, atime, ctime, btime, scm, description, flags)")
+	flagTimeField := flag.String("time", lsConfigData.timeField, "Timestamp to display and sort by: mtime, atime, ctime or btime")
+	flagTimeColumns := flag.String("times", lsConfigData.timeColumnsSpec, "Comma-separated timestamps to display as separate columns (defaults to -time)")
+	flagGroupDirectories := flag.Bool("group", lsConfigData.groupDirectories, "Keep directories grouped together when sorting")
+	flagPager := flag.String("pager", lsConfigData.pager, "Page the listing with: builtin, external ($PAGER, or less -R) or a command")
+	flagNoPager := flag.Bool("nopage", !lsConfigData.autoMore, "Don't page the listing")
+	flagColor := flag.String("color", lsConfigData.colorMode, "Colorize output: auto, always or never")
+	flagRecursive := flag.Bool("R", lsConfigData.recursive, "List subdirectories recursively")
+	flagMaxDepth := flag.Int("depth", lsConfigData.maxDepth, "Limit recursion to this many levels (0 is unlimited)")
+	flagFollowLinks := flag.Bool("follow", lsConfigData.followLinks, "Follow symlinks and reparse points when recursing")
+	flagTreeMode := flag.Bool("T", lsConfigData.treeMode, "Display entries as a tree")
+	flagTreeASCII := flag.Bool("ascii", lsConfigData.treeASCII, "Draw the tree with ASCII characters only")
+	flagWideMode := flag.Bool("W", lsConfigData.wideMode, "Display entry names in multiple columns")
+	flagWatchMode := flag.Bool("watch", lsConfigData.watchMode, "List the directory again whenever its entries change")
+	flagWatchHighlight := flag.Int("highlight", lsConfigData.watchHighlight, "Highlight entries changed while watching for this many seconds")
+	flagSnapshot := flag.String("snapshot", lsConfigData.snapshotFile, "Save the state of the listed entries to this (JSON) file instead of listing them")
+	flagDiff := flag.String("diff", lsConfigData.diffFile, "Mark the entries that have changed since the snapshot saved in this file")
+	flagSnapshotHashes := flag.Bool("snapshot-hash", lsConfigData.snapshotHashes, "Include the hashes of files in the snapshot (by the hash columns' algorithms, or -hash), so changed contents are caught")
+	flagBrowseMode := flag.Bool("B", lsConfigData.browseMode, "Browse interactively, printing the selected paths on exit")
+	flagWideAcross := flag.Bool("across", lsConfigData.wideAcross, "Order multiple columns across rows instead of down columns")
+	flagCollation := flag.String("collate", lsConfigData.collation, "Order names by collation: ordinal, nocase, natural or unicode")
 	var cliConfigs configItems
 	flag.Var(&cliConfigs, "config", "Permanently alter a configuration value")
 
@@ -267,6 +638,49 @@ func parseCommandLine() {
 	lsConfigData.hideLinks = *flagHideLinks
 	lsConfigData.hideMetaData = *flagHideMetaData
 	lsConfigData.compactSizes = !*flagExpandSizes
-	lsConfigData.sortAscending = *flagSortAscending
-	lsConfigData.sortDescending = *flagSortDescending
+	if *flagSI {
+		lsConfigData.sizeUnits = "si"
+	}
+	lsConfigData.blockSize = *flagBlockSize
+	lsConfigData.sizePrecision = *flagSizePrecision
+	lsConfigData.timeField = *flagTimeField
+	lsConfigData.timeColumnsSpec = *flagTimeColumns
+	lsConfigData.timeStyle = *flagTimeStyle
+	lsConfigData.timeZone = *flagTimeZone
+	lsConfigData.timePrecision = *flagTimePrecision
+	lsConfigData.dirSizes = *flagDirSizes
+	lsConfigData.summaryOnly = *flagSummaryOnly
+	lsConfigData.dirSizeTimeout = *flagDirSizeTimeout
+	lsConfigData.elision = *flagElision
+	lsConfigData.ellipsis = *flagEllipsis
+	lsConfigData.metaOverflow = *flagMetaOverflow
+	lsConfigData.metaSourcesSpec = *flagMetaSources
+	lsConfigData.metaDisplay = *flagMetaDisplay
+	lsConfigData.columnsSpec = *flagColumns
+	lsConfigData.hashAlgorithm = *flagHashAlgorithm
+	lsConfigData.hashDigits = *flagHashDigits
+	lsConfigData.sortSpec = *flagSort
+	if *flagSortAscending {
+		lsConfigData.sortSpec = "time"
+	} else if *flagSortDescending {
+		lsConfigData.sortSpec = "time:desc"
+	}
+	lsConfigData.groupDirectories = *flagGroupDirectories
+	lsConfigData.collation = *flagCollation
+	lsConfigData.colorMode = *flagColor
+	lsConfigData.pager = *flagPager
+	lsConfigData.autoMore = !*flagNoPager
+	lsConfigData.recursive = *flagRecursive
+	lsConfigData.maxDepth = *flagMaxDepth
+	lsConfigData.followLinks = *flagFollowLinks
+	lsConfigData.treeMode = *flagTreeMode
+	lsConfigData.treeASCII = *flagTreeASCII
+	lsConfigData.wideMode = *flagWideMode
+	lsConfigData.browseMode = *flagBrowseMode
+	lsConfigData.watchMode = *flagWatchMode
+	lsConfigData.watchHighlight = *flagWatchHighlight
+	lsConfigData.snapshotFile = *flagSnapshot
+	lsConfigData.diffFile = *flagDiff
+	lsConfigData.snapshotHashes = *flagSnapshotHashes
+	lsConfigData.wideAcross = *flagWideAcross
 }
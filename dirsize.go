@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dirUsage ... The recursive size of a directory: the bytes of the files beneath
+// it, the space they occupy on disk, and how many files and subdirectories
+// there are.
+type dirUsage struct {
+	bytes     uint64
+	allocated uint64
+	files     uint64
+	dirs      uint64
+}
+
+// the state shared by every directory measurement of a listing.  A timeout or a
+// Ctrl-C stops all of them, not just the one in progress.
+var dirSizing struct {
+	slots      chan struct{}
+	done       chan struct{}
+	cancel     func()
+	timeout    time.Duration
+	timer      *time.Timer
+	incomplete int32
+}
+
+// startDirSizing ... Prepares the worker pool for measuring directories, and arms
+// the timeout (if there is one).
+func startDirSizing(workers int, timeout time.Duration) {
+	if workers <= 0 {
+		// the work is mostly waiting on the file system
+		workers = runtime.NumCPU() * 4
+	}
+
+	dirSizing.slots = make(chan struct{}, workers)
+	dirSizing.timeout = timeout
+
+	restartDirSizing()
+}
+
+// restartDirSizing ... Begins the measurements of a new listing (such as a redraw
+// in watch mode), with a deadline of their own.
+func restartDirSizing() {
+	if dirSizing.timer != nil {
+		dirSizing.timer.Stop()
+	}
+
+	// a timer left over from an earlier listing can only close its own channel
+	done := make(chan struct{})
+	var stop sync.Once
+	dirSizing.done = done
+	dirSizing.cancel = func() {
+		stop.Do(func() { close(done) })
+	}
+	atomic.StoreInt32(&dirSizing.incomplete, 0)
+
+	if dirSizing.timeout > 0 {
+		dirSizing.timer = time.AfterFunc(dirSizing.timeout, dirSizing.cancel)
+	}
+}
+
+func dirSizingCancelled() bool {
+	select {
+	case <-dirSizing.done:
+		atomic.StoreInt32(&dirSizing.incomplete, 1)
+		return true
+	default:
+	}
+	return false
+}
+
+// dirSizesIncomplete ... Returns true if any measurement was cut short.
+func dirSizesIncomplete() bool {
+	return atomic.LoadInt32(&dirSizing.incomplete) != 0
+}
+
+// clusterBytes returns the space a file of the given size occupies on disk
+func clusterBytes(size uint64, bytesPerSector uint64) uint64 {
+	if bytesPerSector == 0 {
+		return 0
+	}
+	allocated := bytesPerSector * (size / bytesPerSector)
+	if size%bytesPerSector != 0 {
+		allocated += bytesPerSector
+	}
+	return allocated
+}
+
+// runs the function on a worker if one is free, and on the calling goroutine
+// if not (so a deep tree can't exhaust the pool and deadlock)
+func dirSizingTask(wg *sync.WaitGroup, task func()) {
+	select {
+	case dirSizing.slots <- struct{}{}:
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-dirSizing.slots
+				wg.Done()
+			}()
+			task()
+		}()
+	default:
+		task()
+	}
+}
+
+// adds up everything beneath the directory.  Symlinks are counted, but not
+// followed, so the walk can't loop.
+func measureDir(dir string, usage *dirUsage, bytesPerSector uint64, wg *sync.WaitGroup) {
+	if dirSizingCancelled() {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			atomic.AddUint64(&usage.dirs, 1)
+			dirSizingTask(wg, func() {
+				measureDir(path, usage, bytesPerSector, wg)
+			})
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size := uint64(info.Size())
+		atomic.AddUint64(&usage.files, 1)
+		atomic.AddUint64(&usage.bytes, size)
+		atomic.AddUint64(&usage.allocated, clusterBytes(size, bytesPerSector))
+	}
+}
+
+// measureDirs ... Measures each of the directories concurrently, returning their
+// usage in the same order.  The first Ctrl-C stops the measurement (rather than
+// ls) while it's in progress; a second one stops ls.
+func measureDirs(dirs []string, bytesPerSector uint64) []*dirUsage {
+	var wg sync.WaitGroup
+
+	signals := make(chan os.Signal, 1)
+	finished := make(chan struct{})
+	signal.Notify(signals, os.Interrupt)
+	defer func() {
+		signal.Stop(signals)
+		close(finished)
+	}()
+	go func() {
+		select {
+		case <-signals:
+			// hand Ctrl-C back to the default handler
+			signal.Stop(signals)
+			dirSizing.cancel()
+		case <-finished:
+		}
+	}()
+
+	usages := make([]*dirUsage, len(dirs))
+	for i := range dirs {
+		usage := &dirUsage{}
+		usages[i] = usage
+		dir := dirs[i]
+		dirSizingTask(&wg, func() {
+			measureDir(dir, usage, bytesPerSector, &wg)
+		})
+	}
+
+	wg.Wait()
+
+	return usages
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+// the ways text that doesn't fit can be shortened
+const (
+	ELIDE_MIDDLE    = "middle"    // cut characters out of the middle
+	ELIDE_END       = "end"       // keep the start
+	ELIDE_START     = "start"     // keep the end
+	ELIDE_EXTENSION = "extension" // keep the start and the extension
+	ELIDE_PATH      = "path"      // keep the first and last path segments
+)
+
+// parseElision ... Validates the name of an elision strategy.
+func parseElision(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", ELIDE_MIDDLE:
+		return ELIDE_MIDDLE, nil
+	case ELIDE_END:
+		return ELIDE_END, nil
+	case ELIDE_START:
+		return ELIDE_START, nil
+	case ELIDE_EXTENSION, "keep-extension", "ext":
+		return ELIDE_EXTENSION, nil
+	case ELIDE_PATH, "path-segment", "segment":
+		return ELIDE_PATH, nil
+	}
+	return "", fmt.Errorf("unknown elision '%s' (expected middle, end, start, extension or path)", name)
+}
+
+// inner extensions that belong with the one after them, as in "name.tar.gz"
+// or "script.min.js"
+var compoundExtensions = map[string]bool{
+	".tar": true,
+	".min": true,
+	".d":   true,
+}
+
+// extension returns the name's extension, including an inner extension
+// that belongs with it
+func extension(name string) string {
+	ext := filepath.Ext(name)
+	if len(ext) == 0 {
+		return ""
+	}
+	if inner := filepath.Ext(name[:len(name)-len(ext)]); compoundExtensions[strings.ToLower(inner)] {
+		ext = inner + ext
+	}
+	return ext
+}
+
+// graphemes splits the string into its grapheme clusters (the characters a
+// reader sees, like a letter and its accents, or an emoji sequence), so that
+// they are never cut apart
+func graphemes(s string) []string {
+	var clusters []string
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		clusters = append(clusters, g.Str())
+	}
+	return clusters
+}
+
+// clustersWidth returns the display width of the clusters
+func clustersWidth(clusters []string) int {
+	width := 0
+	for _, cluster := range clusters {
+		width += displayWidth(cluster)
+	}
+	return width
+}
+
+// the leading clusters of the string that fit in the width
+func keepStart(s string, width int) string {
+	clusters := graphemes(s)
+	for len(clusters) != 0 && clustersWidth(clusters) > width {
+		clusters = clusters[:len(clusters)-1]
+	}
+	return strings.Join(clusters, "")
+}
+
+// the trailing clusters of the string that fit in the width
+func keepEnd(s string, width int) string {
+	clusters := graphemes(s)
+	for len(clusters) != 0 && clustersWidth(clusters) > width {
+		clusters = clusters[1:]
+	}
+	return strings.Join(clusters, "")
+}
+
+// keeps both ends of the string, taking clusters from either side of the
+// middle in turn until the rest fits
+func elideMiddle(s string, width int) string {
+	clusters := graphemes(s)
+	left := clusters[:len(clusters)/2]
+	right := clusters[len(left):]
+	count := 0
+	for clustersWidth(left)+clustersWidth(right) > width {
+		if ((count&1) == 0 && len(right) != 0) || len(left) == 0 {
+			right = right[1:]
+		} else {
+			left = left[:len(left)-1]
+		}
+		count++
+	}
+	return strings.Join(left, "") + lsConfigData.ellipsis + strings.Join(right, "")
+}
+
+// keeps the first segment of the path and as many of the last segments as
+// fit, e.g., "/home/.../build/output/bin"
+func elidePath(s string, width int) string {
+	separator := "/"
+	if !strings.Contains(s, separator) && strings.ContainsRune(s, os.PathSeparator) {
+		separator = string(os.PathSeparator)
+	}
+
+	segments := strings.Split(s, separator)
+	if len(segments) > 2 {
+		head := segments[0] + separator + lsConfigData.ellipsis
+		tail := ""
+		for i := len(segments) - 1; i > 0; i-- {
+			candidate := separator + segments[i] + tail
+			if displayWidth(head+candidate) > width {
+				break
+			}
+			tail = candidate
+		}
+		if len(tail) != 0 {
+			return head + tail
+		}
+	}
+
+	// not even the last segment fits, so keep as much of the end as will
+	return lsConfigData.ellipsis + keepEnd(s, width-displayWidth(lsConfigData.ellipsis))
+}
+
+// elide ... Shortens the text to fit in the width (including the ellipsis) using
+// the strategy.  The result is never wider than the width: if there isn't room
+// for the ellipsis as well as some of the text, the text is simply cut.
+func elide(text string, width int, strategy string) string {
+	if displayWidth(text) <= width {
+		return text
+	}
+	if width <= 0 {
+		return ""
+	}
+
+	room := width - displayWidth(lsConfigData.ellipsis)
+	if room <= 0 {
+		if strategy == ELIDE_START {
+			return keepEnd(text, width)
+		}
+		return keepStart(text, width)
+	}
+
+	if strategy == ELIDE_PATH {
+		return elidePath(text, width)
+	}
+
+	// directories keep their trailing separator
+	suffix := ""
+	if strings.HasSuffix(text, "/") {
+		suffix = "/"
+		text = text[:len(text)-1]
+		room--
+		if room <= 0 {
+			return keepStart(text, width-1) + suffix
+		}
+	}
+
+	switch strategy {
+	case ELIDE_END:
+		return keepStart(text, room) + lsConfigData.ellipsis + suffix
+	case ELIDE_START:
+		return lsConfigData.ellipsis + keepEnd(text, room) + suffix
+	case ELIDE_EXTENSION:
+		// the last character of the stem is kept as well, so the ellipsis isn't
+		// run together with the extension's dot
+		ext := extension(text)
+		if len(ext) != 0 && len(ext) != len(text) {
+			stem := graphemes(text[:len(text)-len(ext)])
+			last := stem[len(stem)-1]
+			kept := displayWidth(last + ext)
+			if kept <= room/2 {
+				return keepStart(strings.Join(stem[:len(stem)-1], ""), room-kept) + lsConfigData.ellipsis + last + ext + suffix
+			}
+		}
+	}
+
+	return elideMiddle(text, room) + suffix
+}
+
+// elideName ... Shortens an entry's name (and the name it had before being renamed,
+// if it has been) to fit in the width, if long names are being elided.
+func elideName(filename string, original string, width int) string {
+	name := filename
+	if len(original) != 0 {
+		name = fmt.Sprintf("%s [née %s]", filename, original)
+	}
+	if !lsConfigData.elideLongNames || displayWidth(name) <= width {
+		return name
+	}
+	if len(original) == 0 {
+		return elide(filename, width, lsConfigData.elision)
+	}
+
+	// the current name gets priority, but the original keeps at least a
+	// third of the room (if there's enough room to share)
+	annotation := displayWidth(" [née ]")
+	room := width - annotation
+	if room < 3*(displayWidth(lsConfigData.ellipsis)+1) {
+		return elide(name, width, lsConfigData.elision)
+	}
+	nameWidth := displayWidth(filename)
+	if nameWidth > room-room/3 {
+		nameWidth = room - room/3
+	}
+	filename = elide(filename, nameWidth, lsConfigData.elision)
+	original = elide(original, room-displayWidth(filename), lsConfigData.elision)
+	return fmt.Sprintf("%s [née %s]", filename, original)
+}
+
+// the narrowest that shortened metadata is still worth displaying
+const metaMinWidth = 12
+
+// elideMetadata ... Shortens an entry's metadata to fit in the width.  Symlink
+// targets are shortened as paths.  If too little of it would be left (or long
+// text isn't being elided), it isn't displayed at all.
+func elideMetadata(metadata string, metacolor string, width int) string {
+	if displayWidth(metadata) <= width {
+		return metadata
+	}
+	if !lsConfigData.elideLongNames || width < metaMinWidth {
+		return ""
+	}
+
+	if metacolor == "symlink" && strings.HasPrefix(metadata, "@") {
+		return "@" + elide(metadata[1:], width-1, lsConfigData.pathElision)
+	}
+	return elide(metadata, width, lsConfigData.elision)
+}
+
+// what happens to metadata that doesn't fit on its entry's line
+const (
+	META_TRUNCATE = "truncate" // shorten it, moving it to a line of its own if need be
+	META_WRAP     = "wrap"     // wrap it onto lines of its own
+	META_DROP     = "drop"     // leave it out
+)
+
+// parseMetaOverflow ... Validates the name of a metadata overflow mode.
+func parseMetaOverflow(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", META_TRUNCATE, "elide":
+		return META_TRUNCATE, nil
+	case META_WRAP:
+		return META_WRAP, nil
+	case META_DROP, "hide":
+		return META_DROP, nil
+	}
+	return "", fmt.Errorf("unknown metadata overflow '%s' (expected truncate, wrap or drop)", name)
+}
+
+// wrapText breaks the text into lines no wider than the width, at spaces
+// where it can and between characters where it can't
+func wrapText(text string, width int) []string {
+	var lines []string
+
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if len(line) != 0 {
+			candidate = line + " " + word
+		}
+		if displayWidth(candidate) <= width {
+			line = candidate
+			continue
+		}
+		if len(line) != 0 {
+			lines = append(lines, line)
+		}
+
+		// words that are too long on their own are broken up
+		line = ""
+		for _, cluster := range graphemes(word) {
+			if len(line) != 0 && displayWidth(line+cluster) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += cluster
+		}
+	}
+	if len(line) != 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// fitMetadata ... Decides how an entry's metadata is displayed, given the room left
+// on the entry's line and the column that continuation lines start at.  Returns
+// the text to display on the entry's line (if any), and the continuation lines.
+func fitMetadata(metadata string, metacolor string, room int, indent int) (string, []string) {
+	if displayWidth(metadata) <= room {
+		return metadata, nil
+	}
+
+	// leave the last column of the display alone
+	width := termCaps.Width - indent - 1
+
+	switch lsConfigData.metaOverflow {
+	case META_WRAP:
+		if width > 0 {
+			return "", wrapText(metadata, width)
+		}
+	case META_TRUNCATE:
+		if short := elideMetadata(metadata, metacolor, room); len(short) != 0 {
+			return short, nil
+		}
+		if short := elideMetadata(metadata, metacolor, width); len(short) != 0 {
+			return "", []string{short}
+		}
+	}
+
+	return "", nil
+}
+
+// continuationLines ... Renders the continuation lines of an entry's metadata.
+func continuationLines(lines []string, metacolor string, indent string) []string {
+	var rendered []string
+	for _, line := range lines {
+		rendered = append(rendered, indent+lsConfigData.coloring[metacolor].Sprint(line))
+	}
+	return rendered
+}
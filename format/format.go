@@ -3,7 +3,11 @@ package format
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
 )
 
 const (
@@ -11,82 +15,236 @@ const (
 	MEGABYTE  uint64 = KILOBYTE * 1024
 	GIGABYTE  uint64 = MEGABYTE * 1024
 	TERRABYTE uint64 = GIGABYTE * 1024
+	PETABYTE  uint64 = TERRABYTE * 1024
+	EXABYTE   uint64 = PETABYTE * 1024
+)
+
+const (
+	UNITS_IEC   = iota // powers of 1024: KiB, MiB, GiB, ...
+	UNITS_SI           // powers of 1000: kB, MB, GB, ...
+	UNITS_FIXED        // everything in the same unit (e.g., --block-size=M)
 )
 
-// Integer ... Pretty print an integer value using commas, if it is large enough.
-func Integer(n uint64, sep rune) string {
-	s := strconv.FormatInt(int64(n), 10)
+var iecLabels = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+var siLabels = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+
+// NumberFormat ... The separators used to display numbers.
+type NumberFormat struct {
+	Group   string // placed between each group of three digits
+	Decimal string // placed between the integer and the fraction
+}
+
+// Numbers ... The separators used by Integer(), Unsigned() and Float().
+var Numbers = NumberFormat{Group: ",", Decimal: "."}
+
+// inserts the group separator between each group of three digits
+func groupDigits(digits string) string {
+	if len(Numbers.Group) == 0 || len(digits) <= 3 {
+		return digits
+	}
 
-	startOffset := 0
 	var buff bytes.Buffer
 
+	lead := len(digits) % 3
+	if lead == 0 {
+		lead = 3
+	}
+	buff.WriteString(digits[:lead])
+	for i := lead; i < len(digits); i += 3 {
+		buff.WriteString(Numbers.Group)
+		buff.WriteString(digits[i : i+3])
+	}
+
+	return buff.String()
+}
+
+// Unsigned ... Pretty print an unsigned value using group separators, if it is
+// large enough.
+func Unsigned(n uint64) string {
+	return groupDigits(strconv.FormatUint(n, 10))
+}
+
+// Integer ... Pretty print an integer value using group separators, if it is
+// large enough.
+func Integer(n int64) string {
 	if n < 0 {
-		startOffset = 1
-		buff.WriteByte('-')
+		// -(n+1)+1 can't overflow, even for the most negative value
+		return "-" + groupDigits(strconv.FormatUint(uint64(-(n+1))+1, 10))
 	}
+	return Unsigned(uint64(n))
+}
 
-	l := len(s)
+// Float ... Pretty print a value with the given number of decimal places, using
+// the group and decimal separators.
+func Float(value float64, prec int) string {
+	s := strconv.FormatFloat(value, 'f', prec, 64)
 
-	commaIndex := 3 - ((l - startOffset) % 3)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
 
-	if commaIndex == 3 {
-		commaIndex = 0
+	fraction := ""
+	if index := strings.IndexByte(s, '.'); index != -1 {
+		fraction = Numbers.Decimal + s[index+1:]
+		s = s[:index]
 	}
 
-	for i := startOffset; i < l; i++ {
+	return sign + groupDigits(s) + fraction
+}
 
-		if commaIndex == 3 {
-			buff.WriteRune(sep)
-			commaIndex = 0
-		}
-		commaIndex++
+// SizeFormat ... How sizes are displayed.
+type SizeFormat struct {
+	Units      int    // one of the UNITS_* values
+	BlockSize  uint64 // the unit used by UNITS_FIXED, in bytes
+	BlockLabel string // the label of that unit
+	Precision  int    // the number of decimal places of scaled sizes
+	Exact      bool   // display the exact number of bytes instead
+}
 
-		buff.WriteByte(s[i])
+// Sizes ... The format used by Size() and Number().
+var Sizes = SizeFormat{Units: UNITS_IEC, BlockSize: 1, BlockLabel: "B", Precision: 2}
+
+// ParseUnits ... Converts the name of a unit system ("iec" or "si") into one of the
+// UNITS_* values.
+func ParseUnits(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "", "iec", "binary":
+		return UNITS_IEC, nil
+	case "si", "decimal":
+		return UNITS_SI, nil
 	}
+	return UNITS_IEC, fmt.Errorf("unknown size units '%s' (expected iec or si)", name)
+}
 
-	return buff.String()
+// parses a unit suffix: "K" and "KiB" are powers of 1024 and "KB" is a power of
+// 1000 (as with GNU ls), and the case of the letters doesn't matter
+func parseUnit(unit string) (uint64, string, bool) {
+	upper := strings.ToUpper(strings.TrimSpace(unit))
+	if len(upper) == 0 || upper == "B" {
+		return 1, "B", true
+	}
+
+	power := strings.Index("KMGTPE", upper[:1])
+	if power == -1 {
+		return 0, "", false
+	}
+	power++
+
+	switch upper[1:] {
+	case "", "IB":
+		return uint64(math.Pow(1024, float64(power))), iecLabels[power], true
+	case "B":
+		return uint64(math.Pow(1000, float64(power))), siLabels[power], true
+	}
+	return 0, "", false
 }
 
-// Number ... Print an integer value with a size suffix, keeping it within the provided restraints.
-func Number(value uint64, width int32, prec int32, detailed bool, expandSizes bool) string {
-	numString := ""
-	if !expandSizes {
-		widthFmt := ""
-		if prec != 0 {
-			widthFmt = fmt.Sprintf("%%%d.%df %%s", width, prec)
-		} else {
-			widthFmt = fmt.Sprintf("%%%dd %%s", width)
-		}
+// ParseSize ... Converts a size like "1.5GiB", "200k" or "10MB" into bytes.
+func ParseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if end == -1 {
+		end = len(s)
+	}
 
-		var sizeLabel string
-		var sizeVal float64 = 0.0
-
-		if value < KILOBYTE {
-			sizeVal = float64(value)
-			sizeLabel = "B"
-		} else if value < MEGABYTE {
-			sizeVal = (float64(value) / float64(KILOBYTE))
-			sizeLabel = "KiB"
-		} else if value < GIGABYTE {
-			sizeVal = (float64(value) / float64(MEGABYTE))
-			sizeLabel = "MiB"
-		} else if value < TERRABYTE {
-			sizeVal = (float64(value) / float64(GIGABYTE))
-			sizeLabel = "GiB"
-		} else {
-			sizeVal = (float64(value) / float64(TERRABYTE))
-			sizeLabel = "TiB"
+	// a unit on its own (e.g., "M") is one of that unit
+	value := 1.0
+	if end != 0 || len(s) == 0 {
+		var err error
+		value, err = strconv.ParseFloat(s[:end], 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid size '%s'", s)
 		}
+	}
+	multiplier, _, ok := parseUnit(s[end:])
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in '%s'", s)
+	}
 
-		if prec != 0 {
-			numString = fmt.Sprintf(widthFmt, sizeVal, sizeLabel)
-		} else {
-			numString = fmt.Sprintf(widthFmt, int64(sizeVal), sizeLabel)
-		}
-	} else {
-		widthFmt := fmt.Sprintf("%%%ds %%s", width)
-		numString = fmt.Sprintf(widthFmt, Integer(value, ','), "B")
+	bytes := value * float64(multiplier)
+	if bytes >= math.MaxUint64 {
+		return 0, fmt.Errorf("size '%s' is too large", s)
+	}
+	return uint64(bytes), nil
+}
+
+// ParseBlockSize ... Converts a block size like "M", "KiB", "GB" or "4K" into the
+// unit for UNITS_FIXED and its label.  Sizes that aren't a plain unit are
+// displayed as a count of blocks.
+func ParseBlockSize(s string) (uint64, string, error) {
+	size, err := ParseSize(s)
+	if err != nil || size == 0 {
+		return 0, "", fmt.Errorf("invalid block size '%s'", s)
 	}
+	if strings.IndexAny(s, "0123456789.") == -1 {
+		_, label, _ := parseUnit(s)
+		return size, label, nil
+	}
+	return size, "blk", nil
+}
+
+// divides the value down the ladder of units until it is less than the base
+func ladder(value uint64, base float64, labels []string) (float64, string) {
+	scaled := float64(value)
+	unit := 0
+	for scaled >= base && unit < len(labels)-1 {
+		scaled /= base
+		unit++
+	}
+	return scaled, labels[unit]
+}
 
-	return numString
+// scale returns the size in the unit it is displayed with, and that unit's label
+func (f *SizeFormat) scale(value uint64) (float64, string) {
+	switch f.Units {
+	case UNITS_FIXED:
+		return float64(value) / float64(f.BlockSize), f.BlockLabel
+	case UNITS_SI:
+		return ladder(value, 1000, siLabels)
+	}
+	return ladder(value, 1024, iecLabels)
+}
+
+// labelWidth returns the width of the longest label the format uses
+func (f *SizeFormat) labelWidth() int {
+	switch f.Units {
+	case UNITS_FIXED:
+		return len(f.BlockLabel)
+	case UNITS_SI:
+		return 2
+	}
+	return 3
+}
+
+// Size ... Formats the size for a column: the value, then its label padded so
+// that the labels of a column line up.
+func Size(value uint64) string {
+	if Sizes.Exact {
+		return fmt.Sprintf("%s B", Unsigned(value))
+	}
+	scaled, label := Sizes.scale(value)
+	return fmt.Sprintf("%s %-*s", Float(scaled, Sizes.Precision), Sizes.labelWidth(), label)
+}
+
+// Number ... Formats the size for a sentence (e.g., the totals), with the value
+// right-aligned in the provided width.
+func Number(value uint64, width int) string {
+	if Sizes.Exact {
+		return PadLeft(Unsigned(value), width) + " B"
+	}
+	scaled, label := Sizes.scale(value)
+	return PadLeft(Float(scaled, Sizes.Precision), width) + " " + label
+}
+
+// PadLeft ... Right-aligns the text in the width, measured in terminal cells (so
+// multi-byte separators, like no-break spaces, count once).
+func PadLeft(text string, width int) string {
+	if padding := width - runewidth.StringWidth(text); padding > 0 {
+		return strings.Repeat(" ", padding) + text
+	}
+	return text
 }
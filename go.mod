@@ -4,7 +4,10 @@ go 1.16
 
 require (
 	github.com/fatih/color v1.10.0
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/nathan-fiscaletti/consolesize-go v0.0.0-20210105204122-a87d9f614b9d
+	github.com/rivo/uniseg v0.2.0
 	github.com/spf13/viper v1.7.1
-	golang.org/x/sys v0.0.0-20210220050731-9a76102bfb43
+	golang.org/x/sys v0.0.0-20220722155257-8c9f86f7a55f
+	golang.org/x/text v0.3.8
 )
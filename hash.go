@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/b0bh00d/ls/format"
+	"github.com/b0bh00d/ls/term"
+)
+
+// the algorithms files can be hashed with
+const (
+	HASH_SHA256 = "sha256"
+	HASH_SHA1   = "sha1"
+	HASH_MD5    = "md5"
+	HASH_CRC32  = "crc32"
+	HASH_FNV    = "fnv" // 64-bit FNV-1a: fast, but not for spotting tampering
+)
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	HASH_SHA256: sha256.New,
+	HASH_SHA1:   sha1.New,
+	HASH_MD5:    md5.New,
+	HASH_CRC32:  func() hash.Hash { return crc32.NewIEEE() },
+	HASH_FNV:    func() hash.Hash { return fnv.New64a() },
+}
+
+var hashAliases = map[string]string{
+	"sha-256": HASH_SHA256,
+	"sha-1":   HASH_SHA1,
+	"crc":     HASH_CRC32,
+	"fnv1a":   HASH_FNV,
+	"fnv64":   HASH_FNV,
+	"fast":    HASH_FNV,
+}
+
+const (
+	// files this large are remembered between runs; smaller ones are quicker
+	// to hash again than to keep track of
+	hashCacheMinimum = 1024 * 1024
+
+	// hashing this much (that isn't cached) shows its progress
+	hashProgressMinimum = 64 * 1024 * 1024
+)
+
+// parseHashAlgorithm ... Validates the name of a hash algorithm, returning it in its
+// canonical form.
+func parseHashAlgorithm(name string) (string, error) {
+	algorithm := strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := hashAliases[algorithm]; ok {
+		algorithm = alias
+	}
+	if _, ok := hashAlgorithms[algorithm]; !ok {
+		return "", fmt.Errorf("unknown hash algorithm '%s' (use sha256, sha1, md5, crc32 or fnv)", name)
+	}
+	return algorithm, nil
+}
+
+// cachedHashes ... The hashes of a file, which hold as long as its size and
+// modification time don't change.
+type cachedHashes struct {
+	Size     uint64            `json:"size"`
+	Modified time.Time         `json:"mtime"`
+	Hashes   map[string]string `json:"hashes"`
+}
+
+// the hashes computed so far, by absolute path, including those remembered
+// from earlier runs
+var hashCache struct {
+	sync.Mutex
+	loaded bool
+	dirty  bool
+	files  map[string]*cachedHashes
+}
+
+func hashCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ls", "hashes.json")
+}
+
+// loads the hashes remembered from earlier runs, the first time any are needed.
+// The cache is locked by the caller.
+func loadHashCache() {
+	if hashCache.loaded {
+		return
+	}
+	hashCache.loaded = true
+	hashCache.files = make(map[string]*cachedHashes)
+
+	if !lsConfigData.hashCache {
+		return
+	}
+	if file := hashCacheFile(); len(file) != 0 {
+		if data, err := os.ReadFile(file); err == nil {
+			// a damaged cache is simply started over
+			json.Unmarshal(data, &hashCache.files)
+		}
+	}
+}
+
+// saveHashCache ... Remembers the hashes of large files for later runs, if any
+// have been computed since the cache was last saved.  Files that have since
+// disappeared are forgotten.
+func saveHashCache() {
+	hashCache.Lock()
+	defer hashCache.Unlock()
+
+	if !hashCache.dirty || !lsConfigData.hashCache {
+		return
+	}
+	hashCache.dirty = false
+
+	saved := make(map[string]*cachedHashes)
+	for path, cached := range hashCache.files {
+		if cached.Size < hashCacheMinimum {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			saved[path] = cached
+		}
+	}
+
+	file := hashCacheFile()
+	if len(file) == 0 {
+		return
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err == nil {
+		os.WriteFile(file, data, 0644)
+	}
+}
+
+// returns the algorithms whose hashes of the file aren't known yet
+func uncachedHashes(path string, entry *entryData, algorithms []string) []string {
+	hashCache.Lock()
+	defer hashCache.Unlock()
+
+	loadHashCache()
+	cached, ok := hashCache.files[path]
+	if !ok || cached.Size != entry.size || !cached.Modified.Equal(entry.modtime) {
+		return algorithms
+	}
+
+	var missing []string
+	for _, algorithm := range algorithms {
+		if _, ok := cached.Hashes[algorithm]; !ok {
+			missing = append(missing, algorithm)
+		}
+	}
+	return missing
+}
+
+func storeHashes(path string, entry *entryData, hashes map[string]string) {
+	hashCache.Lock()
+	defer hashCache.Unlock()
+
+	cached, ok := hashCache.files[path]
+	if !ok || cached.Size != entry.size || !cached.Modified.Equal(entry.modtime) {
+		cached = &cachedHashes{Size: entry.size, Modified: entry.modtime, Hashes: make(map[string]string)}
+		hashCache.files[path] = cached
+	}
+	for algorithm, digest := range hashes {
+		cached.Hashes[algorithm] = digest
+	}
+	if entry.size >= hashCacheMinimum {
+		hashCache.dirty = true
+	}
+}
+
+// counts the bytes read while hashing, for the progress display
+type hashCounter struct {
+	read *uint64
+}
+
+func (c hashCounter) Write(p []byte) (int, error) {
+	atomic.AddUint64(c.read, uint64(len(p)))
+	return len(p), nil
+}
+
+// hashes the file with each of the algorithms in a single read.  Returns nil if
+// the file can't be read.
+func hashFile(path string, algorithms []string, read *uint64) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	hashers := make([]hash.Hash, len(algorithms))
+	writers := []io.Writer{hashCounter{read}}
+	for i, algorithm := range algorithms {
+		hashers[i] = hashAlgorithms[algorithm]()
+		writers = append(writers, hashers[i])
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil
+	}
+
+	hashes := make(map[string]string)
+	for i, algorithm := range algorithms {
+		hashes[algorithm] = hex.EncodeToString(hashers[i].Sum(nil))
+	}
+	return hashes
+}
+
+// hashProgress shows how much of the hashing has been done until it's stopped,
+// on the terminal (the listing itself may be going to a pipe or a pager)
+func hashProgress(read *uint64, total uint64, files int) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				fmt.Fprint(os.Stderr, "\r\x1b[K")
+				return
+			case <-ticker.C:
+				current := atomic.LoadUint64(read)
+				fmt.Fprintf(os.Stderr, "\r\x1b[K Hashing %d files: %s of %s (%s%%)", files,
+					strings.TrimSpace(format.Size(current)), strings.TrimSpace(format.Size(total)),
+					format.Float(float64(current)*100.0/float64(total), 0))
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// hashFiles ... Hashes the files among the entries (of the current directory)
+// with each of the algorithms, on a pool of lsConfigData.hashWorkers workers.
+// Hashes that are already cached aren't computed again, and new ones are saved
+// straight away (ls may well be quit from the pager, without a chance to save
+// them later).  Progress is shown if there is a lot to read and somebody to
+// show it to.
+func hashFiles(entries []entryData, algorithms []string) {
+	type hashJob struct {
+		path       string
+		entry      *entryData
+		algorithms []string
+	}
+
+	var jobs []hashJob
+	var total uint64
+	for i := range entries {
+		entry := &entries[i]
+		if entry.isDir {
+			continue
+		}
+		path, err := filepath.Abs(entry.file)
+		if err != nil {
+			continue
+		}
+		if missing := uncachedHashes(path, entry, algorithms); len(missing) != 0 {
+			jobs = append(jobs, hashJob{path: path, entry: entry, algorithms: missing})
+			total += entry.size
+		}
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	// the largest files are started first, so they don't hold up the end
+	sort.SliceStable(jobs, func(i int, j int) bool {
+		return jobs[i].entry.size > jobs[j].entry.size
+	})
+
+	var read uint64
+	if total >= hashProgressMinimum && pagerCommand == nil && term.IsTerminal(os.Stderr) {
+		stop := hashProgress(&read, total, len(jobs))
+		defer stop()
+	}
+
+	workers := lsConfigData.hashWorkers
+	if workers <= 0 {
+		// the work is mostly reading, and hashing keeps a processor busy
+		workers = runtime.NumCPU()
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	queue := make(chan hashJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if hashes := hashFile(job.path, job.algorithms, &read); hashes != nil {
+					storeHashes(job.path, job.entry, hashes)
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	saveHashCache()
+}
+
+// entryHash ... Returns the (full, hex) hash of the file with the algorithm, or an
+// empty string if it can't be read.  The file is hashed if hashFiles hasn't
+// already done so.
+func entryHash(entry *entryData, algorithm string) string {
+	if entry.isDir {
+		return ""
+	}
+	path, err := filepath.Abs(entry.file)
+	if err != nil {
+		return ""
+	}
+
+	if len(uncachedHashes(path, entry, []string{algorithm})) != 0 {
+		hashFiles([]entryData{*entry}, []string{algorithm})
+	}
+
+	hashCache.Lock()
+	defer hashCache.Unlock()
+	if cached, ok := hashCache.files[path]; ok && cached.Size == entry.size && cached.Modified.Equal(entry.modtime) {
+		return cached.Hashes[algorithm]
+	}
+	return ""
+}
+
+// lineCount returns the number of lines in the file (a final line without a
+// newline still counts), or -1 if it can't be read
+func lineCount(file string) int {
+	f, err := os.Open(file)
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+
+	count := 0
+	last := byte('\n')
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buffer)
+		if n > 0 {
+			count += bytes.Count(buffer[:n], []byte{'\n'})
+			last = buffer[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return -1
+		}
+	}
+	if last != '\n' {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// localeTag returns the configured locale or, if there isn't one, the locale
+// the environment has set for the category (e.g., "LC_TIME").  POSIX names
+// like "de_DE.UTF-8" become "de-DE"; the "C" and "POSIX" locales (and anything
+// that can't be parsed) are language.Und.
+func localeTag(locale string, category string) language.Tag {
+	if len(locale) == 0 {
+		for _, name := range []string{"LC_ALL", category, "LANG"} {
+			locale = os.Getenv(name)
+			if len(locale) != 0 {
+				break
+			}
+		}
+	}
+
+	if index := strings.IndexAny(locale, ".@"); index != -1 {
+		locale = locale[:index]
+	}
+	locale = strings.Replace(locale, "_", "-", -1)
+
+	tag, err := language.Parse(locale)
+	if err != nil || locale == "C" || locale == "POSIX" {
+		return language.Und
+	}
+	return tag
+}
+
+// numberSeparators returns the group and decimal separators of the locale's
+// numbers, as CLDR has them.  They're taken from a sample number formatted for
+// the locale: whatever comes between its first two runs of digits, and between
+// its last two.  (Where the group separator is a space, CLDR has a no-break
+// one, so a number is never split across lines.)
+func numberSeparators(tag language.Tag) (string, string) {
+	sample := message.NewPrinter(tag).Sprint(number.Decimal(1234567.5, number.Scale(1)))
+
+	var separators []string
+	separator := ""
+	for _, r := range sample {
+		if unicode.IsDigit(r) {
+			if len(separator) != 0 {
+				separators = append(separators, separator)
+				separator = ""
+			}
+			continue
+		}
+		separator += string(r)
+	}
+
+	switch len(separators) {
+	case 0:
+		return ",", "."
+	case 1:
+		// the locale doesn't group digits
+		return "", separators[0]
+	}
+	return separators[0], separators[len(separators)-1]
+}
@@ -4,17 +4,15 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"syscall"
 	"time"
 	"unicode"
-	"unsafe"
 
 	"github.com/fatih/color"
-	"golang.org/x/sys/windows"
+	"github.com/mattn/go-runewidth"
 
 	"github.com/b0bh00d/ls/format"
 	"github.com/b0bh00d/ls/meta"
@@ -25,51 +23,79 @@ import (
 type entryData struct {
 	file    string
 	modtime time.Time
+	atime   time.Time
+	ctime   time.Time
+	btime   time.Time
 	size    uint64
-	sizeDsp float64
-	sizeFmt string
 	stats   string
+	mode    string
+	owner   string
+	group   string
 	symlink string
 	isDir   bool
+	usage   *dirUsage
 }
 
-var totalBytes uint64 = 0
+// what is known about the terminal we're writing to
+var termCaps term.Capabilities
 
-var dllKernel32 *windows.DLL = nil
-var procGetDiskFreeSpaceW *windows.Proc = nil
+// totals for a single directory listing, or for a whole recursive walk
+type listingTotals struct {
+	files     int
+	dirs      int
+	bytes     uint64
+	allocated uint64
 
-type partitionInfo struct {
-	sectorsPerCluster     uint64
-	bytesPerSector        uint64
-	numberOfFreeClusters  uint64
-	totalNumberOfClusters uint64
-	totalBytes            uint64
-	bytesInUse            uint64
+	// the measured contents of the listed directories, which a recursive walk
+	// counts as it goes (so they aren't carried into its grand total)
+	dirBytes     uint64
+	dirAllocated uint64
 }
 
-func getPartInfo(path string) *partitionInfo {
-	var sectorsPerCluster uint32 = 0
-	var bytesPerSector uint32 = 0
-	var numberOfFreeClusters uint32 = 0
-	var totalNumberOfClusters uint32 = 0
+func (t *listingTotals) add(other listingTotals) {
+	t.files += other.files
+	t.dirs += other.dirs
+	t.bytes += other.bytes
+	t.allocated += other.allocated
+}
 
-	result, _, err := procGetDiskFreeSpaceW.Call(uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(path))),
-		uintptr(unsafe.Pointer(&sectorsPerCluster)),
-		uintptr(unsafe.Pointer(&bytesPerSector)),
-		uintptr(unsafe.Pointer(&numberOfFreeClusters)),
-		uintptr(unsafe.Pointer(&totalNumberOfClusters)))
+// directories already listed during a recursive walk, so that links which
+// point back up the tree don't send us around in circles
+type visitedDirs []os.FileInfo
 
-	if result != 1 {
-		log.Panic(err)
+// seen reports whether the directory has already been visited, and makes
+// note of it if it hasn't
+func (v *visitedDirs) seen(dir string) bool {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return true
 	}
+	for _, prev := range *v {
+		if os.SameFile(fi, prev) {
+			return true
+		}
+	}
+	*v = append(*v, fi)
+	return false
+}
 
-	clusterSize := uint64(sectorsPerCluster * bytesPerSector)
-	totalBytes := uint64(totalNumberOfClusters) * clusterSize
-	bytesInUse := totalBytes - uint64(numberOfFreeClusters)*clusterSize
-
-	partInfo := partitionInfo{uint64(sectorsPerCluster), uint64(bytesPerSector), uint64(numberOfFreeClusters), uint64(totalNumberOfClusters), totalBytes, bytesInUse}
+// withinAny reports whether the path is beneath one of the directories
+func withinAny(path string, dirs map[string]bool) bool {
+	for parent := filepath.Dir(path); parent != path; path, parent = parent, filepath.Dir(parent) {
+		if dirs[parent] {
+			return true
+		}
+	}
+	return false
+}
 
-	return &partInfo
+type partitionInfo struct {
+	sectorsPerCluster     uint64
+	bytesPerSector        uint64
+	numberOfFreeClusters  uint64
+	totalNumberOfClusters uint64
+	totalBytes            uint64
+	bytesInUse            uint64
 }
 
 func resolveReparsePoint(file string) string {
@@ -97,146 +123,104 @@ func resolveReparsePoint(file string) string {
 	return target
 }
 
-// some attributes aren't defined in syscall, so I've had to define them here
-const FILE_ATTRIBUTE_COMPRESSED uint32 = 2048
-const FILE_ATTRIBUTE_ENCRYPTED uint32 = 16384
-const FILE_ATTRIBUTE_SPARSE_FILE uint32 = 512
-
-func processStats(file string) (string, string) {
-	flags := []string{"-", "-", "-", "-", "-", "-", "-", "-"}
-
-	ptr, err := syscall.UTF16PtrFromString(file)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	// https://golang.hotexamples.com/examples/syscall/-/GetFileAttributes/golang-getfileattributes-function-examples.html
-	attr, err := syscall.GetFileAttributes(ptr)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	if attr&syscall.FILE_ATTRIBUTE_DIRECTORY != 0 {
-		file = fmt.Sprint(file, "/")
-	}
-
-	if attr&syscall.FILE_ATTRIBUTE_READONLY != 0 {
-		flags[0] = "r"
-	}
-	if attr&syscall.FILE_ATTRIBUTE_ARCHIVE != 0 {
-		flags[1] = "a"
-	}
-	if attr&syscall.FILE_ATTRIBUTE_HIDDEN != 0 {
-		flags[2] = "h"
-	}
-	if attr&syscall.FILE_ATTRIBUTE_SYSTEM != 0 {
-		flags[3] = "s"
-	}
-	if attr&FILE_ATTRIBUTE_COMPRESSED != 0 {
-		flags[4] = "c"
-	}
-	if attr&FILE_ATTRIBUTE_ENCRYPTED != 0 {
-		flags[5] = "e"
-	}
-	if attr&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0 {
-		flags[6] = "S"
-	}
-	if attr&FILE_ATTRIBUTE_SPARSE_FILE != 0 {
-		flags[7] = "p"
-	}
-
-	return file, strings.Join(flags, "")
-}
-
 func processFile(file string) entryData {
 	fi, err := os.Stat(file)
 	if err != nil {
-		log.Fatal(err)
+		// a dangling symlink is listed as the link itself
+		fi, err = os.Lstat(file)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	t := fi.ModTime()
+	atime, ctime, btime := processTimes(file, fi)
 	file, stats := processStats(file)
+	mode, owner, group := processPermissions(file)
 	var symlinkTarget string
 	if stats[6] == 'S' {
 		// this is a reparse point (a.k.a. symlink)
-		symlinkTarget = resolveReparsePoint(file)
-	}
-
-	// var size_str string = fmt.Sprintf("%7s    ", " ")
-	var sizeFmt string
-	if lsConfigData.compactSizes {
-		sizeFmt = fmt.Sprintf("%7s    ", " ")
-	} else {
-		sizeFmt = fmt.Sprintf("%15s    ", " ")
+		symlinkTarget = resolveReparsePoint(strings.TrimSuffix(file, "/"))
 	}
-	var sizeVal = 0.0
 
 	s := uint64(0)
-
 	if !strings.HasSuffix(file, "/") {
 		s = uint64(fi.Size())
-		totalBytes += s
-
-		if lsConfigData.compactSizes {
-			sizeFmt = fmt.Sprintf("%7s    ", " ")
-			if s < format.KILOBYTE {
-				sizeVal = float64(s)
-				sizeFmt = "%7.2f B  "
-			} else if s < format.MEGABYTE {
-				sizeVal = (float64(s) / float64(format.KILOBYTE))
-				sizeFmt = "%7.2f KiB"
-			} else if s < format.GIGABYTE {
-				sizeVal = (float64(s) / float64(format.MEGABYTE))
-				sizeFmt = "%7.2f MiB"
-			} else if s < format.TERRABYTE {
-				sizeVal = (float64(s) / float64(format.GIGABYTE))
-				sizeFmt = "%7.2f GiB"
-			} else {
-				sizeVal = (float64(s) / float64(format.TERRABYTE))
-				sizeFmt = "%7.2f TiB"
-			}
-		} else {
-			sizeVal = float64(s)
-			sizeFmt = "%17s B"
-		}
 	}
 
-	// https://flaviocopes.com/go-date-time-format/
-	// timestamp := t.Format("01/02/06 15:04:05")
-
-	return entryData{file: file, modtime: t, size: s, sizeDsp: sizeVal, sizeFmt: sizeFmt, stats: stats, symlink: symlinkTarget, isDir: fi.IsDir()}
+	return entryData{file: file, modtime: t, atime: atime, ctime: ctime, btime: btime, size: s, stats: stats, mode: mode, owner: owner, group: group, symlink: symlinkTarget, isDir: fi.IsDir()}
 }
 
-func quickSort(a []entryData, ascending bool) []entryData {
-	if len(a) < 2 {
-		return a
-	}
+// listEntries ... Gathers the entries of the current directory that match the
+// patterns (and aren't being hidden), split into directories and files.
+func listEntries(patterns []string) ([]entryData, []entryData) {
+	var dirEntries []entryData
+	var fileEntries []entryData
 
-	left, right := 0, len(a)-1
+	var files []string
 
-	pivot := rand.Int() % len(a)
+	for j := range patterns {
+		ci_pattern := convertToCI(patterns[j])
+		f, err := filepath.Glob(ci_pattern)
+		if err != nil {
+			log.Panic(err)
+		}
+		for i := range f {
+			files = append(files, f[i])
+		}
+	}
 
-	a[pivot], a[right] = a[right], a[pivot]
+	// categorize and file each entry
+	for i := range files {
+		entry := processFile(files[i])
 
-	for i := range a {
-		swap := false
-		if ascending {
-			swap = a[i].modtime.Unix() < a[right].modtime.Unix()
-		} else {
-			swap = a[i].modtime.Unix() > a[right].modtime.Unix()
+		if lsConfigData.hideHidden && entry.stats[2] == 'h' {
+			continue
 		}
-		if swap {
-			a[left], a[i] = a[i], a[left]
-			left++
+		if lsConfigData.hideSystem && entry.stats[3] == 's' {
+			continue
+		}
+
+		if entry.isDir {
+			dirEntries = append(dirEntries, entry)
+		} else {
+			fileEntries = append(fileEntries, entry)
 		}
 	}
 
-	a[left], a[right] = a[right], a[left]
+	return dirEntries, fileEntries
+}
 
-	quickSort(a[:left], ascending)
-	quickSort(a[left+1:], ascending)
+// subdirectories ... Returns the names of the current directory's subdirectories
+// that a recursive listing descends into, in name order.
+func subdirectories() ([]string, error) {
+	dirEntries, err := os.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(dirEntries, func(i int, j int) bool {
+		return compareNames(dirEntries[i].Name(), dirEntries[j].Name()) < 0
+	})
 
-	return a
+	var names []string
+	for _, d := range dirEntries {
+		name, stats := processStats(d.Name())
+		if !strings.HasSuffix(name, "/") {
+			continue
+		}
+		if lsConfigData.hideHidden && stats[2] == 'h' {
+			continue
+		}
+		if lsConfigData.hideSystem && stats[3] == 's' {
+			continue
+		}
+		if stats[6] == 'S' && !lsConfigData.followLinks {
+			continue
+		}
+		names = append(names, d.Name())
+	}
+
+	return names, nil
 }
 
 func colorizeCodes(codes string) string {
@@ -258,400 +242,433 @@ func colorizeCodes(codes string) string {
 	return newString
 }
 
-func main() {
-	rows, cols := term.GetDimensions()
+// scmColumn returns the (colorized) SCM codes column for the entry, along with
+// its original name if the SCM reports it as renamed
+func scmColumn(entry entryData, scmStatus *scm.Status) (string, string) {
+	scmLine := ""
+	scmRename := ""
+
+	if len(scmStatus.Entries) != 0 || len(scmStatus.Deleted) != 0 {
+		scmLine = strings.Repeat(" ", scmStatus.MaxWidth)
+		scmEntry, ok := scmStatus.Entries[entry.file]
+		if ok {
+			scmLine = scmEntry.Codes
+			scmLine += strings.Repeat(" ", scmStatus.MaxWidth-len(scmEntry.Codes))
+			scmLine = colorizeCodes(scmLine)
+			e, ok := scmStatus.Deleted[entry.file]
+			if ok {
+				scmRename = e.Original
+			}
+		}
+		scmLine += " "
+	}
 
-	dllKernel32 = windows.MustLoadDLL("kernel32.dll")
-	procGetDiskFreeSpaceW = dllKernel32.MustFindProc("GetDiskFreeSpaceW")
+	return scmLine, scmRename
+}
 
-	dllMsvcrt := windows.MustLoadDLL("msvcrt.dll")
-	procGetch := dllMsvcrt.MustFindProc("_getch")
+// entryColor returns the color configured for the file's extension, if any
+func entryColor(file string) *color.Color {
+	ext := filepath.Ext(file)
+	if len(ext) != 0 {
+		color, ok := lsConfigData.coloring[strings.ToLower(ext)[1:]]
+		if ok {
+			return color
+		}
+	}
+
+	return nil
+}
 
-	color.NoColor = !term.EnableColor()
+// displayWidth returns the number of terminal columns that the (uncolored)
+// string occupies.  Wide (East Asian) characters take two columns, and
+// combining marks and other zero-width characters take none.
+func displayWidth(s string) int {
+	return runewidth.StringWidth(s)
+}
 
+// https://wenzr.wordpress.com/2018/04/09/go-glob-case-insensitive/
+func convertToCI(line string) string {
+	p := ""
+	for _, r := range line {
+		if unicode.IsLetter(r) {
+			p += fmt.Sprintf("[%c%c]", unicode.ToLower(r), unicode.ToUpper(r))
+		} else {
+			p += string(r)
+		}
+	}
+	return p
+}
+
+func main() {
 	loadConfig()
 	parseCommandLine()
 
-	var tasks = map[string][]string{}
+	colorMode, err := term.ParseColorMode(lsConfigData.colorMode)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	for _, val := range flag.Args() {
-		if stat, err := os.Stat(val); err == nil && stat.IsDir() {
-			// path is a directory
-			tasks[val] = []string{"*"}
-		} else {
-			// handle it as a file pattern
-			d := filepath.Dir(val)
-			f := val
-			if d != "." {
-				f = val[len(d)+1:]
-			}
-			_, ok := tasks[d]
-			if ok {
-				// already has files_to_process
-				tasks[d] = append(tasks[d], f)
-			} else {
-				tasks[d] = []string{f}
-			}
-		}
+	lsConfigData.timeField, err = parseTimeField(lsConfigData.timeField)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	if len(tasks) == 0 {
-		tasks["."] = []string{"*"}
+	lsConfigData.timeColumns, err = parseTimeFields(lsConfigData.timeColumnsSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(lsConfigData.timeColumns) == 0 {
+		lsConfigData.timeColumns = []string{lsConfigData.timeField}
 	}
 
-	cwd, err := os.Getwd()
+	timeFormat, err = newTimeFormatter(lsConfigData.timeStyle, lsConfigData.timeZone, lsConfigData.timePrecision, lsConfigData.timeLocale)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	// lambdas
-	lElideFunc := func(filename string, remaining int) string {
-		if lsConfigData.elideLongNames {
-			line := ""
-			if len(filename) > remaining {
-				left := filename[:len(filename)/2]
-				right := filename[len(left):]
-				newline := left + right
-				count := 0
-				for len(newline) > remaining {
-					if (count & 1) == 0 {
-						right = right[1:]
-					} else {
-						left = left[:len(left)-1]
-					}
-					newline = left + right
-					count++
-				}
-				line = fmt.Sprint(left, "...", right)
-				return line
-			}
-		}
-		return filename
+	format.Numbers.Group, format.Numbers.Decimal = numberSeparators(localeTag(lsConfigData.numberLocale, "LC_NUMERIC"))
+	if lsConfigData.groupSeparator != nil {
+		format.Numbers.Group = *lsConfigData.groupSeparator
+	}
+	if lsConfigData.decimalSeparator != nil {
+		format.Numbers.Decimal = *lsConfigData.decimalSeparator
 	}
 
-	lProcessFile := func(entry entryData, cwd string, scmStatus *scm.Status) string {
-		entrySize := ""
-		if lsConfigData.compactSizes {
-			entrySize = fmt.Sprintf(entry.sizeFmt, entry.sizeDsp)
-			entrySize += strings.Repeat(" ", 11-len(entrySize))
-		} else {
-			entrySize = fmt.Sprintf(entry.sizeFmt, format.Integer(entry.size, ','))
-			entrySize += strings.Repeat(" ", 19-len(entrySize))
+	format.Sizes.Exact = !lsConfigData.compactSizes
+	format.Sizes.Precision = lsConfigData.sizePrecision
+	format.Sizes.Units, err = format.ParseUnits(lsConfigData.sizeUnits)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(lsConfigData.blockSize) != 0 {
+		format.Sizes.BlockSize, format.Sizes.BlockLabel, err = format.ParseBlockSize(lsConfigData.blockSize)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
+		format.Sizes.Units = format.UNITS_FIXED
+	}
 
-		scmLine := ""
-		scmRename := ""
-
-		if len(scmStatus.Entries) != 0 || len(scmStatus.Deleted) != 0 {
-			scmLine = strings.Repeat(" ", scmStatus.MaxWidth)
-			scmEntry, ok := scmStatus.Entries[entry.file]
-			if ok {
-				scmLine = scmEntry.Codes
-				scmLine += strings.Repeat(" ", scmStatus.MaxWidth-len(scmEntry.Codes))
-				scmLine = colorizeCodes(scmLine)
-				e, ok := scmStatus.Deleted[entry.file]
-				if ok {
-					scmRename = e.Original
-				}
+	if lsConfigData.dirSizes || lsConfigData.summaryOnly {
+		var timeout time.Duration
+		if len(lsConfigData.dirSizeTimeout) != 0 {
+			timeout, err = time.ParseDuration(lsConfigData.dirSizeTimeout)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
 			}
-			scmLine += " "
 		}
+		startDirSizing(lsConfigData.dirSizeWorkers, timeout)
+	}
 
-		line := fmt.Sprint(entry.modtime.Format("01/02/06 15:04:05"), " ", entrySize, " ", entry.stats, " ")
-		remaining := cols - (len(line) + len(scmLine)) - 4
+	lsConfigData.elision, err = parseElision(lsConfigData.elision)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	lsConfigData.pathElision, err = parseElision(lsConfigData.pathElision)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-		lineToElide := entry.file
-		if len(scmRename) != 0 {
-			lineToElide = fmt.Sprintf("%s [née %s]", entry.file, scmRename)
-		}
-		line += fmt.Sprint(lElideFunc(lineToElide, remaining))
+	lsConfigData.metaOverflow, err = parseMetaOverflow(lsConfigData.metaOverflow)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-		// retrieve file metadata based on priority
-		metacolor := "description"
-		metadata := ""
-		if !lsConfigData.hideMetaData {
-			metadata = meta.Retrieve(entry.file, cwd)
-		}
-		if len(metadata) == 0 {
-			if len(metadata) == 0 {
-				metadata = ""
-				if !lsConfigData.hideLinks {
-					metadata = entry.symlink
-				}
-				if len(metadata) != 0 {
-					metacolor = "symlink"
-					metadata = fmt.Sprintf("@%s", metadata)
-				}
-			}
+	lsConfigData.metaSources, err = meta.ParseSources(lsConfigData.metaSourcesSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	lsConfigData.metaDisplay, err = parseMetaDisplay(lsConfigData.metaDisplay)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if len(lsConfigData.columnsSpec) != 0 {
+		lsConfigData.columns, err = parseColumns(lsConfigData.columnsSpec)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
 		}
+	} else {
+		lsConfigData.columns = defaultColumns()
+	}
 
-		metaDataLength := len(metadata)
-		if metaDataLength != 0 {
-			needed := len(line) + metaDataLength + 4
-			if needed < cols {
-				colsLeft := cols - len(line) - len(metadata) - 4
-				line += " "
-				line += strings.Repeat("-", colsLeft)
-				line += "> "
-			} else {
-				metaDataLength = 0
-			}
+	if len(lsConfigData.snapshotFile) != 0 && len(lsConfigData.diffFile) != 0 {
+		fmt.Fprintln(os.Stderr, "a snapshot can't be taken and compared with at the same time")
+		os.Exit(2)
+	}
+	if len(lsConfigData.diffFile) != 0 {
+		if lsConfigData.treeMode || lsConfigData.browseMode || lsConfigData.summaryOnly {
+			fmt.Fprintln(os.Stderr, "a snapshot can only be compared with a long, wide or watched listing")
+			os.Exit(2)
 		}
 
-		var fileColor *color.Color = nil
+		snap, err := readSnapshot(lsConfigData.diffFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		diffing = newSnapshotDiff(snap, lsConfigData.diffFile)
 
-		ext := filepath.Ext(entry.file)
-		if len(ext) != 0 {
-			color, ok := lsConfigData.coloring[strings.ToLower(ext)[1:]]
-			if ok {
-				fileColor = color
+		// the comparison covers whatever the snapshot did
+		if snap.Recursive {
+			lsConfigData.recursive = true
+			if lsConfigData.maxDepth == 0 {
+				lsConfigData.maxDepth = snap.Depth
 			}
 		}
 
-		if fileColor != nil {
-			line = fileColor.Sprint(line)
+		// the markers lead the listing, unless the columns place them elsewhere
+		hasDiff := false
+		for _, column := range lsConfigData.columns {
+			hasDiff = hasDiff || column.name == "diff"
 		}
-
-		line = fmt.Sprintf("%s%s", scmLine, line)
-
-		if metaDataLength != 0 {
-			line += lsConfigData.coloring[metacolor].Sprint(metadata)
+		if !hasDiff {
+			lsConfigData.columns = append([]listColumn{listColumns["diff"]}, lsConfigData.columns...)
 		}
+	}
 
-		return line
+	lsConfigData.hashAlgorithm, err = parseHashAlgorithm(lsConfigData.hashAlgorithm)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	lProcessDir := func(entry entryData, cwd string, scmStatus *scm.Status) string {
-		scmLine := ""
-		scmRename := ""
+	lsConfigData.sortKeys, err = parseSortKeys(lsConfigData.sortSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-		if len(scmStatus.Entries) != 0 || len(scmStatus.Deleted) != 0 {
-			scmLine = strings.Repeat(" ", scmStatus.MaxWidth)
-			scmEntry, ok := scmStatus.Entries[entry.file]
-			if ok {
-				scmLine = scmEntry.Codes
-				scmLine += strings.Repeat(" ", scmStatus.MaxWidth-len(scmEntry.Codes))
-				scmLine = colorizeCodes(scmLine)
-				e, ok := scmStatus.Deleted[entry.file]
-				if ok {
-					scmRename = e.Original
-				}
-			}
-			scmLine += " "
-		}
+	compareNames, err = newCollator(lsConfigData.collation, lsConfigData.collationLocale)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-		line := fmt.Sprint(entry.modtime.Format("01/02/06 15:04:05"), " ", entry.sizeFmt, " ", entry.stats, " ")
-		remaining := cols - (len(line) + len(scmLine)) - 4
+	termCaps = term.Detect(colorMode)
+	cols := termCaps.Width
 
-		lineToElide := entry.file
-		if len(scmRename) != 0 {
-			lineToElide = fmt.Sprintf("%s [née %s]", entry.file, scmRename)
-		}
-		line += fmt.Sprint(lElideFunc(lineToElide, remaining))
+	color.NoColor = termCaps.ColorDepth == term.DEPTH_NONE
 
-		// retrieve directory metadata based on priority
-		metacolor := "description"
-		metadata := ""
-		if !lsConfigData.hideMetaData {
-			metadata = meta.Retrieve(entry.file, cwd)
-		}
-		if len(metadata) == 0 {
-			metadata = ""
-			if !lsConfigData.hideLinks {
-				metadata = entry.symlink
-			}
-			if len(metadata) != 0 {
-				metacolor = "symlink"
-				metadata = fmt.Sprintf("@%s", metadata)
-			}
-		}
+	// don't prompt for keypresses when the output is going to a file or pipe
+	if !termCaps.IsTTY {
+		lsConfigData.autoMore = false
+	}
 
-		metaDataLength := len(metadata)
-		if metaDataLength != 0 {
-			needed := (len(line) + len(scmLine)) + metaDataLength + 4
-			if needed < cols {
-				colsLeft := cols - (len(line) + len(scmLine)) - len(metadata) - 4
-				line += " "
-				line += strings.Repeat("-", colsLeft)
-				line += "> "
-			} else {
-				metaDataLength = 0
-			}
-		}
+	meta.XattrNames = lsConfigData.xattrNames
 
-		line = fmt.Sprintf("%s%s", scmLine, lsConfigData.coloring["directories"].Sprint(line))
+	// the browser (or the watched listing) has the whole display to itself
+	if lsConfigData.browseMode || lsConfigData.watchMode {
+		lsConfigData.autoMore = false
+	}
 
-		if metaDataLength != 0 {
-			line += lsConfigData.coloring[metacolor].Sprint(metadata)
-		}
+	startPaging()
+	defer stopPaging()
 
-		return line
-	}
+	var tasks = map[string][]string{}
 
-	linesPrinted := 0
-	lPrintLine := func(line string) {
-		fmt.Println(line)
-		linesPrinted++
-		if lsConfigData.autoMore {
-			if linesPrinted == (rows - 1) {
-				fmt.Print("Press SPACE key to continue...\r")
-				for {
-					result, _, _ := procGetch.Call()
-					if result == ' ' {
-						break
-					} else if result == 3 {
-						fmt.Print("                               ")
-						os.Exit(0)
-					}
-				}
-				linesPrinted = 0
-			}
-		}
-	}
+	// the directories that were given without patterns
+	var unpatterned []string
 
-	// https://wenzr.wordpress.com/2018/04/09/go-glob-case-insensitive/
-	lConvertToCI := func(line string) string {
-		p := ""
-		for _, r := range line {
-			if unicode.IsLetter(r) {
-				p += fmt.Sprintf("[%c%c]", unicode.ToLower(r), unicode.ToUpper(r))
+	for _, val := range flag.Args() {
+		if stat, err := os.Stat(val); err == nil && stat.IsDir() {
+			// path is a directory
+			tasks[val] = []string{"*"}
+			unpatterned = append(unpatterned, val)
+		} else {
+			// handle it as a file pattern
+			d := filepath.Dir(val)
+			f := val
+			if d != "." {
+				f = val[len(d)+1:]
+			}
+			_, ok := tasks[d]
+			if ok {
+				// already has files_to_process
+				tasks[d] = append(tasks[d], f)
 			} else {
-				p += string(r)
+				tasks[d] = []string{f}
 			}
 		}
-		return p
 	}
 
-	firstListing := true
-
-	for key, patterns := range tasks {
-		if !firstListing {
-			fmt.Printf("\n|%s|\n\n", strings.Repeat("-", cols-3))
-			linesPrinted += 3
-		}
-
-		if key != "." && key != cwd {
-			os.Chdir(key)
+	if len(tasks) == 0 {
+		if diffing != nil {
+			// compare the directory the snapshot was taken of
+			tasks[diffing.snap.Root] = []string{"*"}
+			unpatterned = append(unpatterned, diffing.snap.Root)
+		} else {
+			tasks["."] = []string{"*"}
+			unpatterned = append(unpatterned, ".")
 		}
+	}
 
-		cwd, err := filepath.Abs(".")
-		if err != nil {
-			log.Fatal(err)
-		}
-		if cwd[len(cwd)-1] == '\\' {
-			cwd = cwd[:len(cwd)-1]
+	// the entries are compared with those the snapshot recorded, unless other
+	// patterns are given
+	if diffing != nil && len(diffing.snap.Patterns) != 0 {
+		for _, dir := range unpatterned {
+			tasks[dir] = diffing.snap.Patterns
 		}
+	}
 
-		partInfo := getPartInfo(cwd)
-
-		// is this a managed folder?
-		scmStatus := scm.GetScmStatus(cwd)
-
-		maxLineLength := 0
-		allocatedBytes := uint64(0)
+	// browsing, watching, snapshots and comparisons cover a single directory
+	if len(tasks) > 1 && (lsConfigData.browseMode || lsConfigData.watchMode || len(lsConfigData.snapshotFile) != 0 || diffing != nil) {
+		fmt.Fprintln(os.Stderr, "only one directory can be browsed, watched, saved as a snapshot or compared with one")
+		os.Exit(2)
+	}
 
-		var fileEntries []entryData
-		var dirEntries []entryData
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		var files []string
+	// prints the size/slack summary of a listing (or a grand total)
+	lPrintTotals := func(totals listingTotals, partInfo *partitionInfo) {
+		if totals.files != 0 || totals.dirs != 0 {
+			totals.bytes += totals.dirBytes
+			totals.allocated += totals.dirAllocated
+			prefix := fmt.Sprintf("%s in", format.Number(totals.bytes, 20))
+			fileData := ""
+			dirData := ""
 
-		for j := range patterns {
-			ci_pattern := lConvertToCI(patterns[j])
-			f, err := filepath.Glob(ci_pattern)
-			if err != nil {
-				log.Panic(err)
+			if totals.files != 0 {
+				fileData = fmt.Sprintf("%d file", totals.files)
+				if totals.files > 1 {
+					fileData += "s"
+				}
 			}
-			for i := range f {
-				files = append(files, f[i])
+			if totals.dirs != 0 {
+				dirData = fmt.Sprintf("%d dir", totals.dirs)
+				if totals.dirs > 1 {
+					dirData += "s"
+				}
+			}
+			line := prefix
+			if len(fileData) != 0 {
+				line += " " + fileData
+			}
+			if totals.dirs != 0 {
+				if len(fileData) != 0 {
+					line += " and"
+				}
+				line += " " + dirData
+			}
+			if len(fileData) != 0 && partInfo.bytesPerSector > 0 {
+				line += fmt.Sprintf(" / %s allocated (", format.Number(totals.allocated, 0))
+				line += lsConfigData.coloring["description"].Sprintf("%s slack", format.Number(totals.allocated-totals.bytes, 0))
+				line += ")"
 			}
+			printLine(line)
+		} else {
+			printLine(fmt.Sprintf("%20s0 bytes in 0 files and 0 dirs", " "))
 		}
+	}
 
-		// categorize and file each entry
-		for i := range files {
-			entry := processFile(files[i])
+	lPrintPartition := func(partInfo *partitionInfo) {
+		pInUse := (float64(partInfo.bytesInUse) / float64(partInfo.totalBytes)) * 100.0
+		bytesInUse := partInfo.totalBytes - partInfo.bytesInUse
+		pFree := (float64(bytesInUse) / float64(partInfo.totalBytes)) * 100.0
 
-			if lsConfigData.hideHidden && entry.stats[2] == 'h' {
-				continue
-			}
-			if lsConfigData.hideSystem && entry.stats[3] == 's' {
+		printLine(fmt.Sprintf("%s total / %s in use (%s%%) / %s free (%s%%)",
+			format.Number(partInfo.totalBytes, 20),
+			format.Number(partInfo.bytesInUse, 0),
+			format.Float(pInUse, 1),
+			format.Number(bytesInUse, 0),
+			format.Float(pFree, 1)))
+	}
+
+	// fills in the recursive sizes of the directories, and adds them to the totals
+	lMeasureDirs := func(dirEntries []entryData, partInfo *partitionInfo, totals *listingTotals) {
+		var dirs []string
+		var measured []int
+		for i := range dirEntries {
+			// symlinked directories are measured only if links are being followed
+			if dirEntries[i].stats[6] == 'S' && !lsConfigData.followLinks {
 				continue
 			}
+			dirs = append(dirs, strings.TrimSuffix(dirEntries[i].file, "/"))
+			measured = append(measured, i)
+		}
 
-			var entrySize string
-			if entry.isDir {
-				entrySize = entry.sizeFmt
-			} else {
-				entrySize = fmt.Sprint(entry.sizeFmt, entry.sizeDsp)
-				if partInfo.bytesPerSector > 0 {
-					allocatedBytes += partInfo.bytesPerSector * (entry.size / partInfo.bytesPerSector)
-					if entry.size%partInfo.bytesPerSector != 0 {
-						allocatedBytes += partInfo.bytesPerSector
-					}
-				}
-			}
+		for i, usage := range measureDirs(dirs, partInfo.bytesPerSector) {
+			entry := &dirEntries[measured[i]]
+			entry.usage = usage
+			entry.size = usage.bytes
+			totals.dirBytes += usage.bytes
+			totals.dirAllocated += usage.allocated
+		}
+	}
 
-			line := ""
-			if scmStatus.Manager != scm.SCM_NONE {
-				line = strings.Repeat(" ", scmStatus.MaxWidth)
-				scmEntry, ok := scmStatus.Entries[entry.file]
-				if ok {
-					line += fmt.Sprintf("%s ", scmEntry.Codes)
-				}
-			}
+	// lists the entries of the current working directory that match the patterns,
+	// followed by their totals
+	lListDirectory := func(cwd string, patterns []string, partInfo *partitionInfo) listingTotals {
+		var totals listingTotals
 
-			line += fmt.Sprint(entry.modtime.Format("01/02/06 15:04:05"), " ", entrySize, " ", entry.stats, " ", entry.file)
-			l := len(line)
-			if l > maxLineLength {
-				maxLineLength = l
-			}
+		// is this a managed folder?
+		scmStatus := scm.GetScmStatus(cwd)
 
-			if entry.isDir {
-				dirEntries = append(dirEntries, entry)
-			} else {
-				fileEntries = append(fileEntries, entry)
-			}
+		dirEntries, fileEntries := listEntries(patterns)
+		for _, entry := range fileEntries {
+			totals.bytes += entry.size
+			totals.allocated += clusterBytes(entry.size, partInfo.bytesPerSector)
+		}
+
+		totals.files = len(fileEntries)
+		totals.dirs = len(dirEntries)
+
+		if lsConfigData.dirSizes && !lsConfigData.wideMode {
+			lMeasureDirs(dirEntries, partInfo, &totals)
 		}
 
 		patternsDisp := strings.Join(patterns, ",")
 		if strings.Contains(patternsDisp, ",") {
 			patternsDisp = fmt.Sprintf("[%s]", patternsDisp)
 		}
-		lPrintLine(fmt.Sprintf(" Directory of %s\\%s", cwd, patternsDisp))
-		lPrintLine("")
+		dirDisp := strings.TrimSuffix(cwd, string(os.PathSeparator))
+		patternsDisp = fmt.Sprintf("%c%s", os.PathSeparator, patternsDisp)
+		if lsConfigData.elideLongNames {
+			dirDisp = elide(dirDisp, termCaps.Width-displayWidth(" Directory of "+patternsDisp)-1, lsConfigData.pathElision)
+		}
+		printLine(" Directory of " + dirDisp + patternsDisp)
+		printLine("")
 
 		finalLines := []string{}
 
-		if lsConfigData.sortAscending || lsConfigData.sortDescending {
-			entries := append(dirEntries, fileEntries...)
-			ascending := true
-			if !lsConfigData.sortAscending {
-				ascending = false
-			}
-			entries = quickSort(entries, ascending)
-			for i := range entries {
-				if entries[i].isDir {
-					finalLines = append(finalLines, lProcessDir(entries[i], cwd, &scmStatus))
-				} else {
-					finalLines = append(finalLines, lProcessFile(entries[i], cwd, &scmStatus))
-				}
-			}
+		entries := orderEntries(dirEntries, fileEntries, &scmStatus, cwd)
+		if watching != nil {
+			watching.update(entries)
+		}
+		if diffing != nil {
+			diffing.update(cwd, entries)
+		}
+
+		var layout *listingLayout
+		if lsConfigData.wideMode {
+			finalLines = append(finalLines, wideLines(entries, &scmStatus)...)
+			finalLines = append(finalLines, watchRemovedLines(0)...)
+			finalLines = append(finalLines, diffRemovedLines(0)...)
 		} else {
-			if !lsConfigData.fileFirst {
-				for i := range dirEntries {
-					finalLines = append(finalLines, lProcessDir(dirEntries[i], cwd, &scmStatus))
-				}
-				for i := range fileEntries {
-					finalLines = append(finalLines, lProcessFile(fileEntries[i], cwd, &scmStatus))
-				}
-			} else {
-				for i := range fileEntries {
-					finalLines = append(finalLines, lProcessFile(fileEntries[i], cwd, &scmStatus))
-				}
-				for i := range dirEntries {
-					finalLines = append(finalLines, lProcessDir(dirEntries[i], cwd, &scmStatus))
-				}
+			layout = newListingLayout(entries, cwd, &scmStatus, partInfo.bytesPerSector)
+			for i := range entries {
+				finalLines = append(finalLines, layout.row(i, entries[i])...)
 			}
+			finalLines = append(finalLines, watchRemovedLines(layout.nameOffset())...)
+			finalLines = append(finalLines, diffRemovedLines(layout.nameOffset())...)
+		}
 
+		if len(lsConfigData.sortKeys) == 0 {
 			// pick up the case where a file under SCM management has been deleted (and won't
 			// appear in the normal directory listing)
 			if scmStatus.Manager != scm.SCM_NONE {
@@ -663,7 +680,9 @@ func main() {
 						if (e.Bits & scm.STATUS_DELETED) != 0 {
 							if firstLine {
 								scmLine = strings.Repeat(" ", scmStatus.MaxWidth)
-								scmLine += " -----------------"
+								if layout != nil {
+									scmLine += " " + strings.Repeat("-", layout.leaderWidth())
+								}
 								finalLines = append(finalLines, scmLine)
 
 								firstLine = false
@@ -683,63 +702,268 @@ func main() {
 		}
 
 		for _, val := range finalLines {
-			lPrintLine(val)
+			printLine(val)
 		}
 
-		lPrintLine("")
+		if lsConfigData.dirSizes && dirSizesIncomplete() {
+			printLine("")
+			printLine(lsConfigData.coloring["description"].Sprint(" Directory sizes are incomplete (timed out or interrupted)"))
+		}
 
-		if len(fileEntries) != 0 || len(dirEntries) != 0 {
-			prefix := fmt.Sprintf("%s in", format.Number(totalBytes, 20, 2, false, !lsConfigData.compactSizes))
-			fileData := ""
-			dirData := ""
+		printLine("")
 
-			if len(fileEntries) != 0 {
-				fileData = fmt.Sprintf("%d file", len(fileEntries))
-				if len(fileEntries) > 1 {
-					fileData += "s"
+		lPrintTotals(totals, partInfo)
+
+		return totals
+	}
+
+	var visited visitedDirs
+
+	// lists the directory and, when recursing, each of its subdirectories in turn
+	var lWalk func(dir string, patterns []string, depth int, partInfo *partitionInfo, grandTotals *listingTotals)
+	lWalk = func(dir string, patterns []string, depth int, partInfo *partitionInfo, grandTotals *listingTotals) {
+		if err := os.Chdir(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		totals := lListDirectory(dir, patterns, partInfo)
+		grandTotals.add(totals)
+
+		if !lsConfigData.recursive || (lsConfigData.maxDepth > 0 && depth >= lsConfigData.maxDepth) {
+			return
+		}
+
+		names, err := subdirectories()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		for _, name := range names {
+			subdir := filepath.Join(dir, name)
+			if visited.seen(subdir) {
+				continue
+			}
+
+			printLine("")
+			lWalk(subdir, patterns, depth+1, partInfo, grandTotals)
+
+			if err := os.Chdir(dir); err != nil {
+				log.Panic(err)
+			}
+		}
+	}
+
+	// prints just the total size of each argument, like du -s
+	lSummarize := func() {
+		var dirs []string
+		for key := range tasks {
+			dirs = append(dirs, key)
+		}
+		sort.Strings(dirs)
+
+		// what each match adds to the total, so that one beneath another (or
+		// given twice) is only counted once, as du -sc does
+		type matchTotals struct {
+			path   string
+			totals listingTotals
+		}
+		var counted []matchTotals
+		countedDirs := make(map[string]bool)
+
+		for _, key := range dirs {
+			dir := key
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(cwd, key)
+			}
+			if err := os.Chdir(dir); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+
+			partInfo := getPartInfo(dir)
+
+			var matches []string
+			for _, pattern := range tasks[key] {
+				f, err := filepath.Glob(convertToCI(pattern))
+				if err != nil {
+					log.Panic(err)
 				}
+				matches = append(matches, f...)
 			}
-			if len(dirEntries) != 0 {
-				dirData = fmt.Sprintf("%d dir", len(dirEntries))
-				if len(dirEntries) > 1 {
-					dirData += "s"
+
+			var totals listingTotals
+			var subdirs []string
+			matched := make(map[string]bool)
+			for _, match := range matches {
+				if matched[match] {
+					continue
+				}
+				matched[match] = true
+
+				fi, err := os.Lstat(match)
+				if err != nil {
+					continue
+				}
+				if fi.IsDir() {
+					subdirs = append(subdirs, match)
+					continue
 				}
+				file := listingTotals{files: 1, bytes: uint64(fi.Size()), allocated: clusterBytes(uint64(fi.Size()), partInfo.bytesPerSector)}
+				totals.add(file)
+				counted = append(counted, matchTotals{filepath.Join(dir, match), file})
 			}
-			fmt.Print(prefix)
-			if len(fileData) != 0 {
-				fmt.Printf(" %s", fileData)
+			for i, usage := range measureDirs(subdirs, partInfo.bytesPerSector) {
+				subdir := listingTotals{files: int(usage.files), dirs: 1 + int(usage.dirs), bytes: usage.bytes, allocated: usage.allocated}
+				totals.add(subdir)
+				path := filepath.Join(dir, subdirs[i])
+				counted = append(counted, matchTotals{path, subdir})
+				countedDirs[path] = true
 			}
-			if len(dirEntries) != 0 {
-				if len(fileData) != 0 {
-					fmt.Print(" and")
-				}
-				fmt.Printf(" %s", dirData)
+
+			printLine(fmt.Sprintf("%s  %s", format.PadLeft(format.Size(totals.bytes), 16), dir))
+		}
+
+		var grandTotals listingTotals
+		added := make(map[string]bool)
+		for _, match := range counted {
+			if !added[match.path] && !withinAny(match.path, countedDirs) {
+				added[match.path] = true
+				grandTotals.add(match.totals)
 			}
-			if len(fileData) != 0 && partInfo.bytesPerSector > 0 {
-				fmt.Printf(" / %s allocated (", format.Number(allocatedBytes, 0, 2, false, !lsConfigData.compactSizes))
-				lsConfigData.coloring["description"].Printf("%s slack", format.Number(allocatedBytes-totalBytes, 0, 2, false, !lsConfigData.compactSizes))
-				fmt.Print(")")
+		}
+
+		if len(dirs) > 1 {
+			printLine(fmt.Sprintf("%s  total", format.PadLeft(format.Size(grandTotals.bytes), 16)))
+		}
+		if dirSizesIncomplete() {
+			printLine(lsConfigData.coloring["description"].Sprint("Sizes are incomplete (timed out or interrupted)"))
+		}
+	}
+
+	if lsConfigData.summaryOnly {
+		lSummarize()
+		os.Chdir(cwd)
+		return
+	}
+
+	if len(lsConfigData.snapshotFile) != 0 {
+		var dir string
+		var patterns []string
+		for key := range tasks {
+			dir, patterns = key, tasks[key]
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+
+		snap := takeSnapshot(dir, patterns)
+		os.Chdir(cwd)
+		if err := writeSnapshot(lsConfigData.snapshotFile, snap); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		entries := fmt.Sprintf("%d entries", len(snap.Entries))
+		if len(snap.Entries) == 1 {
+			entries = "1 entry"
+		}
+		printLine(fmt.Sprintf(" %s of %s saved to %s", entries, dir, lsConfigData.snapshotFile))
+		return
+	}
+
+	if lsConfigData.browseMode || lsConfigData.watchMode {
+		var dir string
+		var patterns []string
+		for key := range tasks {
+			dir, patterns = key, tasks[key]
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, dir)
+		}
+
+		if lsConfigData.browseMode {
+			for _, path := range browse(dir, patterns) {
+				fmt.Println(path)
 			}
-			lPrintLine("")
 		} else {
-			lPrintLine(fmt.Sprintf("%20s0 bytes in 0 files and 0 dirs", " "))
+			watchDirectory(dir, func() {
+				if err := os.Chdir(dir); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return
+				}
+				// the partition's figures are refreshed along with the listing
+				partInfo := getPartInfo(dir)
+				if lsConfigData.dirSizes {
+					restartDirSizing()
+				}
+				if diffing != nil {
+					diffing.start(dir)
+				}
+				lListDirectory(dir, patterns, partInfo)
+				if diffing != nil {
+					printLine("")
+					for _, line := range diffing.summary() {
+						printLine(line)
+					}
+				}
+				lPrintPartition(partInfo)
+			})
 		}
+		os.Chdir(cwd)
+		return
+	}
 
-		pInUse := (float64(partInfo.bytesInUse) / float64(partInfo.totalBytes)) * 100.0
-		bytesInUse := partInfo.totalBytes - partInfo.bytesInUse
-		pFree := (float64(bytesInUse) / float64(partInfo.totalBytes)) * 100.0
+	firstListing := true
 
-		lPrintLine(fmt.Sprintf("%s total / %s in use (%.1f%%) / %s free (%.1f%%)",
-			format.Number(partInfo.totalBytes, 20, 2, false, !lsConfigData.compactSizes),
-			format.Number(partInfo.bytesInUse, 0, 2, false, !lsConfigData.compactSizes),
-			pInUse,
-			format.Number(bytesInUse, 0, 2, false, !lsConfigData.compactSizes),
-			pFree))
+	for key, patterns := range tasks {
+		if !firstListing {
+			printLine("")
+			printLine(fmt.Sprintf("|%s|", strings.Repeat("-", cols-3)))
+			printLine("")
+		}
 
-		if key != "." {
-			os.Chdir(cwd)
+		dir := key
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(cwd, key)
 		}
 
+		if lsConfigData.treeMode {
+			renderTree(dir, patterns)
+			firstListing = false
+			continue
+		}
+
+		partInfo := getPartInfo(dir)
+
+		visited = nil
+		visited.seen(dir)
+
+		if diffing != nil {
+			diffing.start(dir)
+		}
+
+		var grandTotals listingTotals
+		lWalk(dir, patterns, 0, partInfo, &grandTotals)
+
+		if lsConfigData.recursive {
+			printLine("")
+			printLine(fmt.Sprintf(" Grand total of %s", dir))
+			printLine("")
+			lPrintTotals(grandTotals, partInfo)
+		}
+
+		if diffing != nil {
+			printLine("")
+			for _, line := range diffing.summary() {
+				printLine(line)
+			}
+		}
+
+		lPrintPartition(partInfo)
+
 		firstListing = false
 	}
+
+	os.Chdir(cwd)
 }
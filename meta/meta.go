@@ -9,14 +9,8 @@ import (
 	"strings"
 	"unicode/utf16"
 	"unicode/utf8"
-	"unsafe"
-
-	"golang.org/x/sys/windows"
 )
 
-var dllMeta *windows.DLL = nil
-var procMeta *windows.Proc = nil
-
 // https://stackoverflow.com/questions/15783830/how-to-read-utf16-text-file-to-string-in-golang
 func utf16BytesToString(b []byte) string {
 	utf := make([]uint16, (len(b)+(2-1))/2)
@@ -30,46 +24,6 @@ func utf16BytesToString(b []byte) string {
 	return string(utf16.Decode(utf))
 }
 
-// See if the file (or folder) has a Directory Opus metadata description assigned
-func getMetadata(filename string) string {
-	// Directory Opus stores comments (file and directory) in NTFS Alternate Data Streams (ADS)
-
-	if dllMeta == nil {
-		dll, err := windows.LoadDLL("metadata.dll")
-		if err == nil {
-			dllMeta = dll
-			proc, err := dllMeta.FindProc("retrieve_metadata")
-			if err == nil {
-				procMeta = proc
-			}
-		}
-	}
-
-	result := ""
-	if procMeta != nil {
-		if strings.HasSuffix(filename, "/") {
-			filename = filename[:len(filename)-1]
-		}
-
-		const DOPUS_BUFFER_SIZE = 2048
-		buffer := make([]byte, DOPUS_BUFFER_SIZE)
-		var pBuffer *byte
-		pBuffer = &buffer[0]
-
-		len, _, _ := procMeta.Call(
-			uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(filename))),
-			uintptr(unsafe.Pointer(pBuffer)),
-			uintptr(DOPUS_BUFFER_SIZE))
-
-		if len != 0 {
-			r := utf16BytesToString(buffer)
-			result = r[:len]
-		}
-	}
-
-	return result
-}
-
 // Total Commander descript.ion file (honestly, I don't know if
 // this file is still in use in 2021, but it's here just in case)
 func getDescriptions(descriptions map[string]string) {
@@ -89,7 +43,7 @@ func getDescriptions(descriptions map[string]string) {
 				startChar := line[0]
 				index := 1
 				for line[index] != startChar {
-					filename = fmt.Sprintf("%s%s", filename, line[index])
+					filename = fmt.Sprintf("%s%c", filename, line[index])
 					index++
 				}
 				for line[index] == ' ' {
@@ -115,23 +69,92 @@ func getDescriptions(descriptions map[string]string) {
 var currentWorkingDir string
 var descriptions map[string]string
 
-// Retrieve ... This function will check for several types of metadata on the indicated
-// file, and return any it finds.  If there are more than one found, then they are
-// arbitrarily prioritized.
-func Retrieve(filename string, cdir string) string {
-	meta := getMetadata(filename)
-	if len(meta) == 0 {
+// the places an entry's metadata can come from
+const (
+	SOURCE_OPUS        = "opus"     // a Directory Opus comment (in an ADS, or an xattr)
+	SOURCE_XATTR       = "xattr"    // a comment in one of XattrNames
+	SOURCE_DESCRIPTION = "descript" // a line of the folder's descript.ion file
+	SOURCE_SYMLINK     = "symlink"  // the target of a symlink (known only to the caller)
+)
+
+// Sources ... The order metadata sources are consulted in by default.
+var Sources = []string{SOURCE_OPUS, SOURCE_XATTR, SOURCE_DESCRIPTION, SOURCE_SYMLINK}
+
+var sourceAliases = map[string]string{
+	"ads":          SOURCE_OPUS,
+	"comment":      SOURCE_OPUS,
+	"xdg":          SOURCE_XATTR,
+	"descript.ion": SOURCE_DESCRIPTION,
+	"description":  SOURCE_DESCRIPTION,
+	"link":         SOURCE_SYMLINK,
+	"target":       SOURCE_SYMLINK,
+}
+
+// ParseSources ... Converts a comma-separated list of metadata sources into the
+// order they should be consulted in.  Sources that aren't listed aren't
+// consulted at all.
+func ParseSources(spec string) ([]string, error) {
+	var sources []string
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		name := strings.ToLower(strings.TrimSpace(item))
+		if len(name) == 0 {
+			continue
+		}
+		if alias, ok := sourceAliases[name]; ok {
+			name = alias
+		}
+
+		switch name {
+		case SOURCE_OPUS, SOURCE_XATTR, SOURCE_DESCRIPTION, SOURCE_SYMLINK:
+		default:
+			return nil, fmt.Errorf("unknown metadata source '%s' (expected opus, xattr, descript or symlink)", item)
+		}
+
+		if !seen[name] {
+			sources = append(sources, name)
+			seen[name] = true
+		}
+	}
+
+	return sources, nil
+}
+
+// Lookup ... Returns the metadata the named source holds for the file, or an
+// empty string if it has none.  Symlink targets are left to the caller.
+func Lookup(source string, filename string, cdir string) string {
+	switch source {
+	case SOURCE_OPUS:
+		return getMetadata(filename)
+	case SOURCE_XATTR:
+		return getXattrComment(filename)
+	case SOURCE_DESCRIPTION:
 		// cache the descriptions until the cwd changes
 		if currentWorkingDir != cdir {
 			descriptions = make(map[string]string)
 			getDescriptions(descriptions)
 			currentWorkingDir = cdir
 		}
-		value, ok := descriptions[filename]
-		if ok {
-			meta = value
+		return descriptions[filename]
+	}
+
+	return ""
+}
+
+// XattrNames ... The extended attributes that are checked (in order) for a per-entry
+// comment on file systems that support them.  Dolphin and Nautilus write
+// "user.xdg.comment".
+var XattrNames = []string{"user.xdg.comment", "user.comment"}
+
+// Retrieve ... This function will check for several types of metadata on the indicated
+// file, and return the first it finds, in the default order of Sources.
+func Retrieve(filename string, cdir string) string {
+	for _, source := range Sources {
+		if meta := Lookup(source, filename, cdir); len(meta) != 0 {
+			return meta
 		}
 	}
 
-	return meta
+	return ""
 }
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package meta
+
+import (
+	"strings"
+)
+
+// See if the file (or folder) has a Directory Opus metadata description assigned.
+// NTFS volumes mounted by ntfs-3g with streams_interface=xattr expose Alternate
+// Data Streams as "user." extended attributes.
+func getMetadata(filename string) string {
+	value := getXattr(strings.TrimSuffix(filename, "/"), "user."+OpusStreamName)
+	if len(value) == 0 {
+		return ""
+	}
+
+	opus, err := ParseOpusMetadataBytes(value)
+	if err != nil {
+		return ""
+	}
+
+	return opus.Comment
+}
+
+// See if the file (or folder) has a comment stored in one of the configured
+// extended attributes
+func getXattrComment(filename string) string {
+	filename = strings.TrimSuffix(filename, "/")
+	for _, name := range XattrNames {
+		value := strings.TrimSpace(strings.TrimRight(string(getXattr(filename, name)), "\x00"))
+		if len(value) != 0 {
+			return value
+		}
+	}
+
+	return ""
+}
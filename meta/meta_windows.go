@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package meta
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var dllMeta *windows.DLL = nil
+var procMeta *windows.Proc = nil
+
+// See if the file (or folder) has a Directory Opus metadata description assigned
+func getMetadata(filename string) string {
+	// Directory Opus stores comments (file and directory) in NTFS Alternate Data Streams (ADS)
+	filename = strings.TrimSuffix(filename, "/")
+
+	opus, err := ReadOpusMetadataFile(fmt.Sprintf("%s:%s", filename, OpusStreamName))
+	if err == nil && len(opus.Comment) != 0 {
+		return opus.Comment
+	}
+
+	// fall back to the legacy DLL, which can also read the summary
+	// information property set of OLE documents
+	if dllMeta == nil {
+		dll, err := windows.LoadDLL("metadata.dll")
+		if err == nil {
+			dllMeta = dll
+			proc, err := dllMeta.FindProc("retrieve_metadata")
+			if err == nil {
+				procMeta = proc
+			}
+		}
+	}
+
+	result := ""
+	if procMeta != nil {
+		const DOPUS_BUFFER_SIZE = 2048
+		buffer := make([]byte, DOPUS_BUFFER_SIZE)
+		var pBuffer *byte
+		pBuffer = &buffer[0]
+
+		len, _, _ := procMeta.Call(
+			uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(filename))),
+			uintptr(unsafe.Pointer(pBuffer)),
+			uintptr(DOPUS_BUFFER_SIZE))
+
+		if len != 0 {
+			r := utf16BytesToString(buffer)
+			result = r[:len]
+		}
+	}
+
+	return result
+}
+
+// NTFS has no user-visible extended attributes; comments live in ADSs instead
+func getXattrComment(filename string) string {
+	return ""
+}
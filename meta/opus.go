@@ -0,0 +1,85 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// OpusStreamName ... The name of the NTFS Alternate Data Stream in which Directory Opus
+// keeps its per-entry metadata.  Tools that expose ADSs in other ways (e.g., ntfs-3g's
+// streams_interface=xattr) use this same name.
+const OpusStreamName = "\007OpusMetaInformation"
+
+// the stream starts with four little-endian 32-bit values: the size of the
+// header (including any padding), flags, rating and the comment length in
+// UTF-16 characters.  The comment itself follows the header.
+const opusHeaderSize = 16
+
+// keep a corrupt length field from allocating huge buffers
+const opusMaxCommentSize = 64 * 1024
+
+// OpusMetadata ... The decoded contents of a Directory Opus metadata stream.
+type OpusMetadata struct {
+	Flags   uint32
+	Rating  uint32
+	Comment string
+}
+
+// ParseOpusMetadata ... Decodes a Directory Opus metadata stream from the provided
+// reader.  The reader can be an opened ADS, the value of an extended attribute, or
+// a stream that has been copied out to a regular file.
+func ParseOpusMetadata(r io.Reader) (*OpusMetadata, error) {
+	var header [4]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+
+	size := header[0]
+	commentSize := header[3]
+
+	if size < opusHeaderSize {
+		return nil, errors.New("meta: invalid Opus metadata header size")
+	}
+	if commentSize > opusMaxCommentSize {
+		return nil, errors.New("meta: invalid Opus metadata comment size")
+	}
+
+	// skip any padding
+	if _, err := io.CopyN(io.Discard, r, int64(size-opusHeaderSize)); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, commentSize*2)
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		return nil, err
+	}
+
+	comment := utf16BytesToString(buffer)
+	if index := strings.IndexRune(comment, 0); index != -1 {
+		comment = comment[:index]
+	}
+
+	return &OpusMetadata{Flags: header[1], Rating: header[2], Comment: comment}, nil
+}
+
+// ParseOpusMetadataBytes ... Decodes a Directory Opus metadata stream held in memory.
+func ParseOpusMetadataBytes(b []byte) (*OpusMetadata, error) {
+	return ParseOpusMetadata(bytes.NewReader(b))
+}
+
+// ReadOpusMetadataFile ... Decodes the Directory Opus metadata stream found at the
+// indicated path.  On Windows, this can be the ADS itself (e.g.,
+// "file.txt:\007OpusMetaInformation").
+func ReadOpusMetadataFile(path string) (*OpusMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseOpusMetadata(file)
+}
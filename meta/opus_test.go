@@ -0,0 +1,98 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// opusStream builds a metadata stream: the header, padding to the header size
+// and the comment (as UTF-16LE), with commentSize as the recorded length
+func opusStream(size uint32, flags uint32, rating uint32, commentSize uint32, comment string) []byte {
+	var stream bytes.Buffer
+	binary.Write(&stream, binary.LittleEndian, [4]uint32{size, flags, rating, commentSize})
+	if size > opusHeaderSize {
+		stream.Write(make([]byte, size-opusHeaderSize))
+	}
+	binary.Write(&stream, binary.LittleEndian, utf16.Encode([]rune(comment)))
+	return stream.Bytes()
+}
+
+func TestParseOpusMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  []byte
+		want    OpusMetadata
+		wantErr bool
+	}{
+		{
+			name:   "comment",
+			stream: opusStream(16, 1, 3, 7, "a notë!"),
+			want:   OpusMetadata{Flags: 1, Rating: 3, Comment: "a notë!"},
+		},
+		{
+			name:   "header padding",
+			stream: opusStream(24, 0, 5, 4, "note"),
+			want:   OpusMetadata{Rating: 5, Comment: "note"},
+		},
+		{
+			name:   "NUL-terminated comment",
+			stream: opusStream(16, 0, 0, 8, "note\x00xyz"),
+			want:   OpusMetadata{Comment: "note"},
+		},
+		{
+			name:   "empty comment",
+			stream: opusStream(16, 0, 0, 0, ""),
+			want:   OpusMetadata{},
+		},
+		{
+			name:    "truncated header",
+			stream:  opusStream(16, 0, 0, 4, "note")[:10],
+			wantErr: true,
+		},
+		{
+			name:    "truncated padding",
+			stream:  opusStream(64, 0, 0, 0, "")[:32],
+			wantErr: true,
+		},
+		{
+			name:    "truncated comment",
+			stream:  opusStream(16, 0, 0, 4, "note")[:20],
+			wantErr: true,
+		},
+		{
+			name:    "header size below 16",
+			stream:  opusStream(12, 0, 0, 4, "note"),
+			wantErr: true,
+		},
+		{
+			name:    "comment size larger than the stream",
+			stream:  opusStream(16, 0, 0, 100, "note"),
+			wantErr: true,
+		},
+		{
+			name:    "comment size beyond the limit",
+			stream:  opusStream(16, 0, 0, opusMaxCommentSize+1, "note"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseOpusMetadataBytes(test.stream)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != test.want {
+				t.Errorf("got %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows && !linux && !darwin && !freebsd && !netbsd
+// +build !windows,!linux,!darwin,!freebsd,!netbsd
+
+package meta
+
+// getXattr has no extended attributes to read on this platform, so entries
+// never carry Opus metadata or xattr comments
+func getXattr(path string, name string) []byte {
+	return nil
+}
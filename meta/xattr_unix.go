@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || netbsd
+// +build linux darwin freebsd netbsd
+
+package meta
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// getXattr returns the value of the named extended attribute, or nil if the
+// entry doesn't carry it (or the file system doesn't support them)
+func getXattr(path string, name string) []byte {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil || size <= 0 {
+		return nil
+	}
+
+	buffer := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, buffer)
+	if err != nil {
+		return nil
+	}
+
+	return buffer[:size]
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/b0bh00d/ls/meta"
+)
+
+// how an entry's metadata is displayed when more than one source has some
+const (
+	META_FIRST = "first" // only the first source (in priority order) that has any
+	META_ALL   = "all"   // all of them, joined on the entry's line
+	META_LINES = "lines" // the first on the entry's line, the rest on lines of their own
+)
+
+// what joins the metadata of several sources on a single line
+const metaSeparator = " | "
+
+// parseMetaDisplay ... Validates the name of a metadata display mode.
+func parseMetaDisplay(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", META_FIRST, "winner":
+		return META_FIRST, nil
+	case META_ALL, "joined":
+		return META_ALL, nil
+	case META_LINES, "each":
+		return META_LINES, nil
+	}
+	return "", fmt.Errorf("unknown metadata display '%s' (expected first, all or lines)", name)
+}
+
+// metaItem ... A piece of an entry's metadata, and the source it came from.
+type metaItem struct {
+	source string
+	text   string
+}
+
+// colorKey returns the key of the color the item is displayed in: the source's
+// own ("meta.<source>" in the "color" section) if one is configured, otherwise
+// the description (or symlink) color
+func (m metaItem) colorKey() string {
+	if m.source == meta.SOURCE_SYMLINK {
+		return "symlink"
+	}
+	if _, ok := lsConfigData.coloring["meta."+m.source]; ok {
+		return "meta." + m.source
+	}
+	return "description"
+}
+
+// entryMetadata ... Gathers the entry's metadata from the configured sources, in
+// their order of priority.  Unless every source is being displayed, only the
+// first one that has any is returned.
+func entryMetadata(entry entryData, cwd string) []metaItem {
+	return gatherMetadata(entry, cwd, lsConfigData.metaDisplay != META_FIRST)
+}
+
+// gatherMetadata returns the entry's metadata from every configured source that
+// has some, or just from the first
+func gatherMetadata(entry entryData, cwd string, all bool) []metaItem {
+	var items []metaItem
+
+	for _, source := range lsConfigData.metaSources {
+		text := ""
+		if source == meta.SOURCE_SYMLINK {
+			if !lsConfigData.hideLinks && len(entry.symlink) != 0 {
+				text = "@" + entry.symlink
+			}
+		} else if !lsConfigData.hideMetaData {
+			text = meta.Lookup(source, entry.file, cwd)
+		}
+		if len(text) == 0 {
+			continue
+		}
+
+		items = append(items, metaItem{source, text})
+		if !all {
+			break
+		}
+	}
+
+	return items
+}
+
+// metadataText joins the text of the items
+func metadataText(items []metaItem) string {
+	var texts []string
+	for _, item := range items {
+		texts = append(texts, item.text)
+	}
+	return strings.Join(texts, metaSeparator)
+}
+
+// placeMetadata ... Lays out an entry's metadata, given the room left on the
+// entry's line and the indentation of continuation lines.  Returns the text
+// displayed on the entry's line, that text in color, and the rendered
+// continuation lines.  Joined metadata keeps the color of each source as long as
+// it is displayed whole; once shortened or wrapped, it takes the color of the
+// first.
+func placeMetadata(items []metaItem, room int, indent string) (string, string, []string) {
+	if len(items) == 0 {
+		return "", "", nil
+	}
+
+	first := items
+	var rest []metaItem
+	if lsConfigData.metaDisplay == META_LINES {
+		first, rest = items[:1], items[1:]
+	}
+
+	metacolor := first[0].colorKey()
+	text := metadataText(first)
+	shown, continued := fitMetadata(text, metacolor, room, displayWidth(indent))
+
+	rendered := ""
+	if shown == text {
+		var parts []string
+		for _, item := range first {
+			parts = append(parts, lsConfigData.coloring[item.colorKey()].Sprint(item.text))
+		}
+		rendered = strings.Join(parts, metaSeparator)
+	} else if len(shown) != 0 {
+		rendered = lsConfigData.coloring[metacolor].Sprint(shown)
+	}
+	lines := continuationLines(continued, metacolor, indent)
+
+	// leave the last column of the display alone
+	width := termCaps.Width - displayWidth(indent) - 1
+	for _, item := range rest {
+		line, continued := fitMetadata(item.text, item.colorKey(), width, displayWidth(indent))
+		if len(line) != 0 {
+			continued = append([]string{line}, continued...)
+		}
+		lines = append(lines, continuationLines(continued, item.colorKey(), indent)...)
+	}
+
+	return shown, rendered, lines
+}
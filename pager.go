@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/b0bh00d/ls/term"
+)
+
+// the pagers the listing can be displayed with
+const (
+	PAGER_BUILTIN  = "builtin"  // pause after each screenful, with paging back and searching
+	PAGER_EXTERNAL = "external" // pipe everything to $PAGER
+)
+
+// the escape sequences (colors and hyperlinks) that take no room on the display
+var escapeSequences = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]|\x1b\\]8;[^\x07\x1b]*(\x07|\x1b\\\\)")
+
+// stripEscapes returns the text of a rendered line, without its escape sequences
+func stripEscapes(line string) string {
+	return escapeSequences.ReplaceAllString(line, "")
+}
+
+// lineRows returns the number of rows the line occupies once the terminal has
+// wrapped it
+func lineRows(line string) int {
+	width := displayWidth(stripEscapes(line))
+	if width <= termCaps.Width {
+		return 1
+	}
+	return (width + termCaps.Width - 1) / termCaps.Width
+}
+
+// linePager ... The built-in pager.  Every line of the listing is kept, so that the
+// user can page back through it and search it while the rest is still being
+// produced.
+type linePager struct {
+	lines     []string
+	pos       int    // the next line to display
+	allowance int    // the rows that can be displayed before pausing
+	pattern   string // the last search (lower-cased)
+	searching bool   // looking for the pattern in lines yet to come
+	prompted  string // the prompt on the display, if any
+}
+
+// the built-in pager, if the listing is being paged with it
+var pager *linePager
+
+// the external pager, if the listing is being piped to one
+var pagerCommand *exec.Cmd
+var pagerInput io.WriteCloser
+
+const pagerPrompt = "-- More -- SPACE: page  ENTER: line  b: back  /: search  q: quit"
+
+// the lines left above a match, so that a description found on a continuation
+// line is displayed along with its entry
+const searchContext = 1
+
+// startPaging ... Sets up the configured pager, if the listing is to be paged at all.
+// An external pager that can't be found falls back to the built-in one.
+func startPaging() {
+	if !lsConfigData.autoMore {
+		return
+	}
+
+	if spec := strings.ToLower(lsConfigData.pager); spec != "" && spec != PAGER_BUILTIN && spec != "more" {
+		command := lsConfigData.pager
+		if spec == PAGER_EXTERNAL {
+			command = os.Getenv("PAGER")
+			if len(command) == 0 {
+				command = defaultPager
+			}
+		}
+
+		if fields := strings.Fields(command); len(fields) != 0 {
+			if _, err := exec.LookPath(fields[0]); err == nil {
+				pagerCommand = shellCommand(command)
+				if len(os.Getenv("LESS")) == 0 {
+					// like git, let less pass colors through and quit if the
+					// listing fits on the screen
+					pagerCommand.Env = append(os.Environ(), "LESS=FRX")
+				}
+				pagerCommand.Stdout = os.Stdout
+				pagerCommand.Stderr = os.Stderr
+				input, err := pagerCommand.StdinPipe()
+				if err == nil {
+					err = pagerCommand.Start()
+				}
+				if err == nil {
+					pagerInput = input
+					return
+				}
+				pagerCommand = nil
+			}
+		}
+	}
+
+	// clearing the screen to page back needs the same escape sequences as color
+	term.EnableColor()
+	pager = &linePager{allowance: pageRows()}
+}
+
+// stopPaging ... Displays whatever the pager is still holding back, and waits for an
+// external pager to be closed.
+func stopPaging() {
+	if pager != nil {
+		pager.finish()
+		pager = nil
+	}
+	if pagerCommand != nil {
+		pagerInput.Close()
+		pagerCommand.Wait()
+		pagerCommand = nil
+	}
+}
+
+// printLine ... Prints a line of the listing, through the pager if there is one.
+func printLine(line string) {
+	switch {
+	case pager != nil:
+		pager.add(line)
+	case pagerCommand != nil:
+		if _, err := fmt.Fprintln(pagerInput, line); err != nil {
+			// the user quit the pager, so there is nobody left to list for
+			pagerCommand.Wait()
+			os.Exit(0)
+		}
+	default:
+		fmt.Println(line)
+	}
+}
+
+// the rows of the display that lines of the listing can occupy (the last one
+// is kept for the prompt)
+func pageRows() int {
+	if termCaps.Height > 2 {
+		return termCaps.Height - 1
+	}
+	return 1
+}
+
+func (p *linePager) add(line string) {
+	p.lines = append(p.lines, line)
+	if p.searching {
+		if !p.matches(len(p.lines) - 1) {
+			return
+		}
+		p.searching = false
+		p.redraw(p.context(len(p.lines) - 1))
+	}
+	p.pump()
+}
+
+// pump displays the lines that are due, pausing whenever the display is full
+func (p *linePager) pump() {
+	for p.pos < len(p.lines) && !p.searching {
+		rows := lineRows(p.lines[p.pos])
+		// a line taller than the display is shown on a display of its own
+		if rows > p.allowance && p.allowance < pageRows() {
+			p.prompt()
+			continue
+		}
+		fmt.Println(p.lines[p.pos])
+		p.pos++
+		p.allowance -= rows
+	}
+}
+
+func (p *linePager) finish() {
+	if p.searching {
+		p.searching = false
+		p.redraw(p.back(len(p.lines), pageRows()-1))
+		p.pump()
+		fmt.Println(lsConfigData.coloring["description"].Sprint("Pattern not found: " + p.pattern))
+		return
+	}
+	p.pump()
+}
+
+// back returns the first of the lines that, ending at the indicated one, fill
+// no more than the rows
+func (p *linePager) back(end int, rows int) int {
+	start := end
+	used := 0
+	for start > 0 {
+		r := lineRows(p.lines[start-1])
+		if used+r > rows {
+			break
+		}
+		used += r
+		start--
+	}
+	return start
+}
+
+// redraw clears the display and starts a new screenful at the line
+func (p *linePager) redraw(top int) {
+	fmt.Print("\x1b[H\x1b[2J")
+	p.prompted = ""
+	p.pos = top
+	p.allowance = pageRows()
+}
+
+func (p *linePager) matches(index int) bool {
+	return strings.Contains(strings.ToLower(stripEscapes(p.lines[index])), p.pattern)
+}
+
+// context returns the line to display a match at the index under
+func (p *linePager) context(index int) int {
+	if index < searchContext {
+		return 0
+	}
+	return index - searchContext
+}
+
+// search looks for the pattern from the line after the (context of the) top
+// of the display, and carries on looking in the lines to come if none of those
+// have it
+func (p *linePager) search() {
+	for i := p.back(p.pos, pageRows()) + searchContext + 1; i < len(p.lines); i++ {
+		if p.matches(i) {
+			p.redraw(p.context(i))
+			return
+		}
+	}
+	p.searching = true
+	p.show("Searching for " + p.pattern + "...")
+}
+
+func (p *linePager) show(prompt string) {
+	p.clear()
+	// a prompt that wrapped couldn't be erased
+	prompt = keepStart(prompt, termCaps.Width-1)
+	fmt.Print(prompt)
+	p.prompted = prompt
+}
+
+// clear erases the prompt
+func (p *linePager) clear() {
+	if len(p.prompted) != 0 {
+		fmt.Print("\r" + strings.Repeat(" ", displayWidth(p.prompted)) + "\r")
+		p.prompted = ""
+	}
+}
+
+// prompt waits for a key that decides what is displayed next
+func (p *linePager) prompt() {
+	p.show(pagerPrompt)
+	defer func() {
+		// the search prompt stays up until the search is over
+		if !p.searching {
+			p.clear()
+		}
+	}()
+
+	for {
+		switch key := term.ReadKey(); key {
+		case ' ', 'f', term.KEY_PAGE_DOWN:
+			p.allowance = pageRows()
+			return
+		case '\r', '\n', 'j', term.KEY_DOWN:
+			p.allowance = lineRows(p.lines[p.pos])
+			return
+		case 'b', term.KEY_PAGE_UP:
+			p.redraw(p.back(p.back(p.pos, pageRows()), pageRows()))
+			return
+		case 'k', term.KEY_UP:
+			if top := p.back(p.pos, pageRows()); top > 0 {
+				p.redraw(top - 1)
+				return
+			}
+		case '/':
+			if pattern, ok := p.readPattern(); ok {
+				if len(pattern) != 0 {
+					p.pattern = strings.ToLower(pattern)
+				}
+				if len(p.pattern) != 0 {
+					p.search()
+					return
+				}
+			}
+			p.show(pagerPrompt)
+		case 'n':
+			if len(p.pattern) != 0 {
+				p.search()
+				return
+			}
+		case 'q', 'Q', 3, 27:
+			p.clear()
+			os.Exit(0)
+		case term.KEY_ERROR:
+			// no keyboard to read from, so stop paging
+			p.allowance = int(^uint(0) >> 1)
+			return
+		}
+	}
+}
+
+// readPattern reads the text to search for on the prompt line.  Returns false
+// if the search is abandoned.
+func (p *linePager) readPattern() (string, bool) {
+	p.show("/")
+
+	var text []rune
+	for {
+		key := term.ReadKey()
+		switch {
+		case key == '\r' || key == '\n':
+			return string(text), true
+		case key == 27 || key == 3 || key == term.KEY_ERROR:
+			return "", false
+		case key == 8 || key == 127:
+			if len(text) != 0 {
+				text = text[:len(text)-1]
+				p.show("/" + string(text))
+			}
+		case key >= ' ':
+			text = append(text, rune(key))
+			p.show("/" + string(text))
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "os/exec"
+
+// the pager used when $PAGER isn't set
+const defaultPager = "less -R"
+
+// external pagers are started by the shell, so that $PAGER can carry arguments
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os/exec"
+
+// the pager used when $PAGER isn't set (less is available from Git for Windows,
+// MSYS2 and Scoop, among others)
+const defaultPager = "less -R"
+
+// external pagers are started by the command interpreter, so that $PAGER can
+// carry arguments
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
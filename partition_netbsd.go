@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/sys/unix"
+)
+
+// NetBSD has no statfs(2), only statvfs(2), which counts blocks in fragments;
+// as on the other POSIX systems, one fragment stands in for a cluster
+func getPartInfo(path string) *partitionInfo {
+	var st unix.Statvfs_t
+
+	err := unix.Statvfs(path, &st)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	blockSize := uint64(st.Frsize)
+	totalBytes := uint64(st.Blocks) * blockSize
+	bytesInUse := totalBytes - uint64(st.Bavail)*blockSize
+
+	partInfo := partitionInfo{1, blockSize, uint64(st.Bavail), uint64(st.Blocks), totalBytes, bytesInUse}
+
+	return &partInfo
+}
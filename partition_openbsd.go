@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+
+	"golang.org/x/sys/unix"
+)
+
+// OpenBSD's statfs(2) prefixes its fields with F_; as on the other POSIX
+// systems, one block stands in for a cluster
+func getPartInfo(path string) *partitionInfo {
+	var st unix.Statfs_t
+
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	blockSize := uint64(st.F_bsize)
+	totalBytes := uint64(st.F_blocks) * blockSize
+	bytesInUse := totalBytes - uint64(st.F_bavail)*blockSize
+
+	partInfo := partitionInfo{1, blockSize, uint64(st.F_bavail), uint64(st.F_blocks), totalBytes, bytesInUse}
+
+	return &partInfo
+}
@@ -0,0 +1,29 @@
+//go:build !windows && !netbsd && !openbsd
+// +build !windows,!netbsd,!openbsd
+
+package main
+
+import (
+	"log"
+
+	"golang.org/x/sys/unix"
+)
+
+// POSIX has no notion of clusters, so the file system block size stands in
+// for the sector size, with one "sector" per "cluster"
+func getPartInfo(path string) *partitionInfo {
+	var st unix.Statfs_t
+
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	blockSize := uint64(st.Bsize)
+	totalBytes := uint64(st.Blocks) * blockSize
+	bytesInUse := totalBytes - uint64(st.Bavail)*blockSize
+
+	partInfo := partitionInfo{1, blockSize, uint64(st.Bavail), uint64(st.Blocks), totalBytes, bytesInUse}
+
+	return &partInfo
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"log"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var dllKernel32 *windows.DLL = nil
+var procGetDiskFreeSpaceW *windows.Proc = nil
+
+func getPartInfo(path string) *partitionInfo {
+	if dllKernel32 == nil {
+		dllKernel32 = windows.MustLoadDLL("kernel32.dll")
+		procGetDiskFreeSpaceW = dllKernel32.MustFindProc("GetDiskFreeSpaceW")
+	}
+
+	var sectorsPerCluster uint32 = 0
+	var bytesPerSector uint32 = 0
+	var numberOfFreeClusters uint32 = 0
+	var totalNumberOfClusters uint32 = 0
+
+	result, _, err := procGetDiskFreeSpaceW.Call(uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(path))),
+		uintptr(unsafe.Pointer(&sectorsPerCluster)),
+		uintptr(unsafe.Pointer(&bytesPerSector)),
+		uintptr(unsafe.Pointer(&numberOfFreeClusters)),
+		uintptr(unsafe.Pointer(&totalNumberOfClusters)))
+
+	if result != 1 {
+		log.Panic(err)
+	}
+
+	clusterSize := uint64(sectorsPerCluster * bytesPerSector)
+	totalBytes := uint64(totalNumberOfClusters) * clusterSize
+	bytesInUse := totalBytes - uint64(numberOfFreeClusters)*clusterSize
+
+	partInfo := partitionInfo{uint64(sectorsPerCluster), uint64(bytesPerSector), uint64(numberOfFreeClusters), uint64(totalNumberOfClusters), totalBytes, bytesInUse}
+
+	return &partInfo
+}
@@ -0,0 +1,62 @@
+package scm
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// LastAuthors ... Returns the author of the most recent commit to each of the
+// named entries of the current folder (directories, named with a trailing
+// "/", get the author of the latest commit to anything beneath them).  Only
+// git is supported; entries with no history are left out of the result.
+func LastAuthors(manager int, names []string) map[string]string {
+	authors := make(map[string]string)
+	if manager != SCM_GIT || len(names) == 0 {
+		return authors
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	// the log is newest first, so the first author seen for an entry is the
+	// one we want, and the log can be abandoned once every entry has one
+	cmd := exec.Command("git", "log", "--format=%x00%an", "--name-only", "--relative", ".")
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return authors
+	}
+	if err = cmd.Start(); err != nil {
+		return authors
+	}
+
+	author := ""
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() && len(authors) < len(wanted) {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x00") {
+			author = line[1:]
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		file := line
+		pathItems := strings.Split(filepath.ToSlash(line), "/")
+		if len(pathItems) > 1 {
+			file = pathItems[0] + "/"
+		}
+		if _, ok := authors[file]; !ok && wanted[file] {
+			authors[file] = author
+		}
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	return authors
+}
@@ -1,7 +1,6 @@
 package scm
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"os/exec"
@@ -47,15 +46,15 @@ func detectScm(cwd string) int {
 	}
 
 	getScm := func(d string) int {
-		s := fmt.Sprint(d, "\\.svn")
+		s := filepath.Join(d, ".svn")
 		if _, err := os.Stat(s); err == nil {
 			return SCM_SVN
 		}
-		s = fmt.Sprint(d, "\\.hg")
+		s = filepath.Join(d, ".hg")
 		if _, err = os.Stat(s); err == nil {
 			return SCM_HG
 		}
-		s = fmt.Sprint(d, "\\.git")
+		s = filepath.Join(d, ".git")
 		if _, err = os.Stat(s); err == nil {
 			return SCM_GIT
 		}
@@ -63,17 +62,21 @@ func detectScm(cwd string) int {
 	}
 
 	for {
+		// the managers refuse to report on their own metadata folders
+		switch filepath.Base(target) {
+		case ".svn", ".hg", ".git":
+			return SCM_NONE
+		}
+
 		scm := getScm(target)
 		if scm != SCM_NONE {
 			return scm
 		}
-		if len(target) == 3 && strings.HasSuffix(target, "\\") {
+		parent := filepath.Dir(target)
+		if parent == target {
 			break // we're at the top of the partition
 		}
-		target, err = filepath.Abs(fmt.Sprint(target, "\\.."))
-		if err != nil {
-			log.Panic(err)
-		}
+		target = parent
 	}
 
 	return SCM_NONE
@@ -103,7 +106,7 @@ func getSubversionStatus(status *Status) {
 				j++
 			}
 			file := strings.TrimSpace(line[j:])
-			pathItems := strings.Split(file, "\\")
+			pathItems := strings.Split(filepath.ToSlash(file), "/")
 			if len(pathItems) > 1 {
 				file = pathItems[0] + "/"
 			}
@@ -195,7 +198,7 @@ func getMercurialStatus(status *Status) {
 				j++
 			}
 			file := strings.TrimSpace(line[j:])
-			pathItems := strings.Split(file, "\\")
+			pathItems := strings.Split(filepath.ToSlash(file), "/")
 			if len(pathItems) > 1 {
 				file = pathItems[0] + "/"
 			}
@@ -260,6 +263,13 @@ func getGitStatus(status *Status) {
 
 	status.MaxWidth = 0
 
+	// porcelain paths are always relative to the top of the repository, so the
+	// location of the current folder within it has to be stripped from them
+	prefix := ""
+	if output, err := exec.Command("git", "rev-parse", "--show-prefix").Output(); err == nil {
+		prefix = strings.TrimSpace(string(output))
+	}
+
 	items := strings.Split(string(output), "\n")
 	for _, line := range items {
 		if len(line) != 0 {
@@ -272,7 +282,11 @@ func getGitStatus(status *Status) {
 				j++
 			}
 			file := strings.TrimSpace(line[j:])
-			pathItems := strings.Split(file, "\\")
+			if len(prefix) != 0 {
+				file = strings.TrimPrefix(file, prefix)
+				file = strings.Replace(file, " -> "+prefix, " -> ", 1)
+			}
+			pathItems := strings.Split(filepath.ToSlash(file), "/")
 			if len(pathItems) > 1 {
 				file = pathItems[0] + "/"
 			}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b0bh00d/ls/format"
+	"github.com/b0bh00d/ls/scm"
+)
+
+// snapshotEntry ... What a snapshot records about an entry.  Paths are relative to
+// the snapshot's root and use forward slashes; those of directories end in one.
+type snapshotEntry struct {
+	Path       string            `json:"path"`
+	Size       uint64            `json:"size"`
+	Modified   time.Time         `json:"mtime"`
+	Attributes string            `json:"attributes"`
+	Mode       string            `json:"mode,omitempty"`
+	Hashes     map[string]string `json:"hashes,omitempty"`
+	SCM        string            `json:"scm,omitempty"`
+}
+
+// snapshot ... The entries of a directory (and, if it was taken recursively, of
+// its subdirectories) at the time the snapshot was taken.
+type snapshot struct {
+	Root      string          `json:"root"`
+	Patterns  []string        `json:"patterns"`
+	Recursive bool            `json:"recursive"`
+	Depth     int             `json:"depth,omitempty"`
+	Taken     time.Time       `json:"taken"`
+	Entries   []snapshotEntry `json:"entries"`
+}
+
+// the ways an entry can differ from the snapshot
+const (
+	DIFF_ADDED   = "added"
+	DIFF_REMOVED = "removed"
+	DIFF_GROWN   = "grown"
+	DIFF_SHRUNK  = "shrunk"
+	DIFF_TOUCHED = "touched" // the same size, but a different timestamp, attributes or hash
+)
+
+var diffMarkers = map[string]string{
+	DIFF_ADDED:   "+",
+	DIFF_REMOVED: "-",
+	DIFF_GROWN:   ">",
+	DIFF_SHRUNK:  "<",
+	DIFF_TOUCHED: "~",
+}
+
+// changes are highlighted in the same colors as they are in watch mode
+var diffColors = map[string]string{
+	DIFF_ADDED:   "watch." + WATCH_ADDED,
+	DIFF_REMOVED: "watch." + WATCH_REMOVED,
+	DIFF_GROWN:   "watch." + WATCH_MODIFIED,
+	DIFF_SHRUNK:  "watch." + WATCH_MODIFIED,
+	DIFF_TOUCHED: "watch." + WATCH_MODIFIED,
+}
+
+// snapshotPath returns the path of an entry of the directory, relative to the
+// root of the snapshot
+func snapshotPath(root string, dir string, file string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return file
+	}
+	return filepath.ToSlash(rel) + "/" + file
+}
+
+// snapshotParent returns the path of the directory an entry of the snapshot is
+// in ("" for the root)
+func snapshotParent(path string) string {
+	if index := strings.LastIndex(strings.TrimSuffix(path, "/"), "/"); index >= 0 {
+		return path[:index+1]
+	}
+	return ""
+}
+
+// snapshotAlgorithms returns the algorithms files are hashed with for a
+// snapshot: those of the hash columns, or the one the hash column would use
+func snapshotAlgorithms() []string {
+	if algorithms := hashColumns(lsConfigData.columns); len(algorithms) != 0 {
+		return algorithms
+	}
+	return []string{lsConfigData.hashAlgorithm}
+}
+
+// takeSnapshot ... Records the entries of the directory that match the patterns,
+// descending into its subdirectories if the listing is recursive.  Files are
+// hashed if lsConfigData.snapshotHashes is set.
+func takeSnapshot(root string, patterns []string) *snapshot {
+	snap := snapshot{Root: root, Patterns: patterns, Recursive: lsConfigData.recursive, Taken: time.Now()}
+	if snap.Recursive {
+		snap.Depth = lsConfigData.maxDepth
+	}
+
+	var algorithms []string
+	if lsConfigData.snapshotHashes {
+		algorithms = snapshotAlgorithms()
+	}
+
+	var visited visitedDirs
+	visited.seen(root)
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		if err := os.Chdir(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		scmStatus := scm.GetScmStatus(dir)
+		dirEntries, fileEntries := listEntries(patterns)
+		if len(algorithms) != 0 {
+			hashFiles(fileEntries, algorithms)
+		}
+		for _, entry := range append(dirEntries, fileEntries...) {
+			record := snapshotEntry{
+				Path:       snapshotPath(root, dir, entry.file),
+				Size:       entry.size,
+				Modified:   entry.modtime,
+				Attributes: entry.stats,
+				Mode:       entry.mode,
+			}
+			if len(algorithms) != 0 && !entry.isDir {
+				record.Hashes = make(map[string]string)
+				for _, algorithm := range algorithms {
+					if digest := entryHash(&entry, algorithm); len(digest) != 0 {
+						record.Hashes[algorithm] = digest
+					}
+				}
+			}
+			if scmEntry, ok := scmStatus.Entries[entry.file]; ok {
+				record.SCM = scmEntry.Codes
+			}
+			snap.Entries = append(snap.Entries, record)
+		}
+
+		if !lsConfigData.recursive || (lsConfigData.maxDepth > 0 && depth >= lsConfigData.maxDepth) {
+			return
+		}
+
+		names, err := subdirectories()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		for _, name := range names {
+			subdir := filepath.Join(dir, name)
+			if !visited.seen(subdir) {
+				walk(subdir, depth+1)
+			}
+		}
+	}
+	walk(root, 0)
+
+	return &snap
+}
+
+// writeSnapshot ... Saves the snapshot to the file, as JSON.
+func writeSnapshot(file string, snap *snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// readSnapshot ... Loads a snapshot saved by writeSnapshot.
+func readSnapshot(file string) (*snapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s is not a snapshot: %v", file, err)
+	}
+	return &snap, nil
+}
+
+// snapshotDiff ... Compares the listing with a snapshot as it is produced: the
+// changes of the entries of the directory being listed, and the totals of the
+// changes found so far.
+type snapshotDiff struct {
+	snap     *snapshot
+	file     string
+	root     string
+	prefix   string
+	entries  map[string]*snapshotEntry
+	children map[string][]string
+	dirs     map[string]bool
+	seen     map[string]bool
+	listed   map[string]string
+	counts   map[string]int
+	bytes    uint64
+}
+
+// the snapshot being compared with, if any
+var diffing *snapshotDiff
+
+func newSnapshotDiff(snap *snapshot, file string) *snapshotDiff {
+	d := snapshotDiff{snap: snap, file: file, entries: make(map[string]*snapshotEntry), children: make(map[string][]string)}
+	for i := range snap.Entries {
+		path := snap.Entries[i].Path
+		d.entries[path] = &snap.Entries[i]
+
+		parent := snapshotParent(path)
+		d.children[parent] = append(d.children[parent], path)
+	}
+	return &d
+}
+
+// start begins a comparison of the listing of the directory
+func (d *snapshotDiff) start(root string) {
+	d.root = root
+	d.dirs = make(map[string]bool)
+	d.seen = make(map[string]bool)
+	d.counts = make(map[string]int)
+	d.bytes = 0
+}
+
+// update compares the entries of the directory about to be listed with the
+// snapshot
+func (d *snapshotDiff) update(dir string, entries []entryData) {
+	d.prefix = snapshotPath(d.root, dir, "")
+	d.dirs[d.prefix] = true
+	d.listed = make(map[string]string)
+
+	// the files whose contents have to be compared are hashed all at once
+	algorithms := make(map[string]bool)
+	var hashing []entryData
+	for i := range entries {
+		if previous, ok := d.entries[d.prefix+entries[i].file]; ok && !entries[i].isDir && entries[i].size == previous.Size {
+			for algorithm := range previous.Hashes {
+				algorithms[algorithm] = true
+			}
+			if len(previous.Hashes) != 0 {
+				hashing = append(hashing, entries[i])
+			}
+		}
+	}
+	if len(hashing) != 0 {
+		var names []string
+		for algorithm := range algorithms {
+			names = append(names, algorithm)
+		}
+		sort.Strings(names)
+		hashFiles(hashing, names)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		path := d.prefix + entry.file
+		d.seen[path] = true
+		if !entry.isDir {
+			d.bytes += entry.size
+		}
+		if change := d.compare(path, entry); len(change) != 0 {
+			d.listed[entry.file] = change
+			d.counts[change]++
+		}
+	}
+}
+
+// compare returns how the entry differs from the snapshot, if it does.  Only
+// the appearance of directories is compared, since their timestamps change
+// along with their contents.
+func (d *snapshotDiff) compare(path string, entry *entryData) string {
+	previous, ok := d.entries[path]
+	switch {
+	case !ok:
+		return DIFF_ADDED
+	case entry.isDir:
+		return ""
+	case entry.size > previous.Size:
+		return DIFF_GROWN
+	case entry.size < previous.Size:
+		return DIFF_SHRUNK
+	case !entry.modtime.Equal(previous.Modified) || entry.stats != previous.Attributes || entry.mode != previous.Mode:
+		return DIFF_TOUCHED
+	}
+	for algorithm, digest := range previous.Hashes {
+		if _, ok := hashAlgorithms[algorithm]; ok && entryHash(entry, algorithm) != digest {
+			return DIFF_TOUCHED
+		}
+	}
+	return ""
+}
+
+// diffCell renders the marker of the entry's change since the snapshot
+func diffCell(entry *entryData, ctx *columnContext) string {
+	if diffing == nil {
+		return ""
+	}
+	return diffMarkers[diffing.listed[entry.file]]
+}
+
+// changeHighlight ... Returns the color key an entry is highlighted with, if it has
+// changed (while being watched, or since the snapshot being compared with).
+func changeHighlight(file string) string {
+	if highlight := watchHighlight(file); len(highlight) != 0 {
+		return highlight
+	}
+	if diffing != nil {
+		if change, ok := diffing.listed[file]; ok {
+			return diffColors[change]
+		}
+	}
+	return ""
+}
+
+// diffRemovedLines ... Renders the entries of the directory being listed that were
+// in the snapshot but are gone: their markers, with their names starting at the
+// indent (or just past the marker, if the indent leaves no room for it).
+func diffRemovedLines(indent int) []string {
+	if diffing == nil {
+		return nil
+	}
+
+	var names []string
+	for _, path := range diffing.children[diffing.prefix] {
+		if !diffing.seen[path] {
+			names = append(names, strings.TrimPrefix(path, diffing.prefix))
+		}
+	}
+	sort.Slice(names, func(i int, j int) bool {
+		return compareNames(names[i], names[j]) < 0
+	})
+
+	gap := indent - 1
+	if gap < 1 {
+		gap = 1
+	}
+
+	removed := lsConfigData.coloring[diffColors[DIFF_REMOVED]]
+	var lines []string
+	for _, name := range names {
+		name = elideName(name, "", termCaps.Width-gap-2)
+		lines = append(lines, removed.Sprint(diffMarkers[DIFF_REMOVED]+strings.Repeat(" ", gap)+name))
+	}
+	return lines
+}
+
+// summary ... Renders the footer of the comparison: the number of entries that
+// have changed in each way, and how much the files have grown (or shrunk) by.
+// Only the directories that have been listed are compared.
+func (d *snapshotDiff) summary() []string {
+	removed := 0
+	var before uint64
+	for path, entry := range d.entries {
+		if !d.dirs[snapshotParent(path)] {
+			continue
+		}
+		if !d.seen[path] {
+			removed++
+		}
+		if !strings.HasSuffix(path, "/") {
+			before += entry.Size
+		}
+	}
+
+	changes := fmt.Sprintf(" Since %s (%s): %d added, %d removed, %d grown, %d shrunk, %d touched",
+		strings.TrimSpace(timeFormat.format(d.snap.Taken)), filepath.Base(d.file),
+		d.counts[DIFF_ADDED], removed, d.counts[DIFF_GROWN], d.counts[DIFF_SHRUNK], d.counts[DIFF_TOUCHED])
+
+	growth := "+" + strings.TrimSpace(format.Size(d.bytes-before))
+	if d.bytes < before {
+		growth = "-" + strings.TrimSpace(format.Size(before-d.bytes))
+	}
+	bytes := fmt.Sprintf(" %s -> %s (%s)", strings.TrimSpace(format.Size(before)), strings.TrimSpace(format.Size(d.bytes)), growth)
+
+	return []string{changes, bytes}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/b0bh00d/ls/scm"
+)
+
+// everything a sort key might need to know about the folder being listed
+type sortContext struct {
+	cwd       string
+	scmStatus *scm.Status
+	metadata  map[string]string
+}
+
+// sortKey ... A single key of a multi-key sort.  compare returns a negative value
+// if a sorts before b, a positive value if it sorts after, and zero if the key
+// can't tell them apart (leaving it to the next key).
+type sortKey struct {
+	name       string
+	descending bool
+	compare    func(a *entryData, b *entryData, ctx *sortContext) int
+}
+
+func compareStrings(a string, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareByName(a *entryData, b *entryData, ctx *sortContext) int {
+	return compareNames(a.file, b.file)
+}
+
+func compareByExtension(a *entryData, b *entryData, ctx *sortContext) int {
+	return compareStrings(strings.ToLower(filepath.Ext(a.file)), strings.ToLower(filepath.Ext(b.file)))
+}
+
+func compareBySize(a *entryData, b *entryData, ctx *sortContext) int {
+	switch {
+	case a.size < b.size:
+		return -1
+	case a.size > b.size:
+		return 1
+	}
+	return 0
+}
+
+// compareByTime returns a comparison of the given timestamp.  An empty field
+// compares the one selected for display.
+func compareByTime(field string) func(a *entryData, b *entryData, ctx *sortContext) int {
+	return func(a *entryData, b *entryData, ctx *sortContext) int {
+		which := field
+		if len(which) == 0 {
+			which = lsConfigData.timeField
+		}
+		ta := entryTime(a, which)
+		tb := entryTime(b, which)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+}
+
+func compareByScm(a *entryData, b *entryData, ctx *sortContext) int {
+	codes := func(e *entryData) string {
+		if scmEntry, ok := ctx.scmStatus.Entries[e.file]; ok {
+			return strings.TrimSpace(scmEntry.Codes)
+		}
+		return ""
+	}
+	return compareStrings(codes(a), codes(b))
+}
+
+func compareByMetadata(a *entryData, b *entryData, ctx *sortContext) int {
+	metadata := func(e *entryData) string {
+		value, ok := ctx.metadata[e.file]
+		if !ok {
+			value = metadataText(entryMetadata(*e, ctx.cwd))
+			ctx.metadata[e.file] = value
+		}
+		return strings.ToLower(value)
+	}
+	return compareStrings(metadata(a), metadata(b))
+}
+
+func compareByAttributes(a *entryData, b *entryData, ctx *sortContext) int {
+	return compareStrings(a.stats, b.stats)
+}
+
+var sortKeyFuncs = map[string]func(a *entryData, b *entryData, ctx *sortContext) int{
+	"name":        compareByName,
+	"ext":         compareByExtension,
+	"extension":   compareByExtension,
+	"size":        compareBySize,
+	"time":        compareByTime(""),
+	"mtime":       compareByTime(TIME_MODIFIED),
+	"atime":       compareByTime(TIME_ACCESSED),
+	"ctime":       compareByTime(TIME_CHANGED),
+	"btime":       compareByTime(TIME_BIRTH),
+	"scm":         compareByScm,
+	"status":      compareByScm,
+	"description": compareByMetadata,
+	"meta":        compareByMetadata,
+	"metadata":    compareByMetadata,
+	"flags":       compareByAttributes,
+	"attributes":  compareByAttributes,
+}
+
+// parseSortKeys ... Converts a sort specification like "ext,size:desc" into the
+// list of keys it describes.  Each key may be followed by ":asc" (the default)
+// or ":desc".
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		key := sortKey{name: strings.ToLower(item)}
+		if index := strings.Index(item, ":"); index != -1 {
+			key.name = strings.ToLower(item[:index])
+			switch strings.ToLower(item[index+1:]) {
+			case "asc", "ascending":
+				key.descending = false
+			case "desc", "descending":
+				key.descending = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction in '%s' (expected asc or desc)", item)
+			}
+		}
+
+		compare, ok := sortKeyFuncs[key.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort key '%s'", key.name)
+		}
+		key.compare = compare
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// the order entries are displayed in when no sort keys have been given
+var nameSortKeys = []sortKey{{name: "name", compare: compareByName}}
+
+// sortEntries ... Sorts the entries by the provided keys.  The sort is stable, so
+// entries that can't be told apart keep their directory order.
+func sortEntries(entries []entryData, keys []sortKey, scmStatus *scm.Status, cwd string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	ctx := sortContext{cwd: cwd, scmStatus: scmStatus, metadata: make(map[string]string)}
+
+	sort.SliceStable(entries, func(i int, j int) bool {
+		for _, key := range keys {
+			result := key.compare(&entries[i], &entries[j], &ctx)
+			if result != 0 {
+				if key.descending {
+					return result > 0
+				}
+				return result < 0
+			}
+		}
+		return false
+	})
+}
+
+// orderEntries ... Arranges the entries of a folder for display.  Directories are
+// grouped before the files (or after, if fileFirst is set) unless they are being
+// sorted and groupDirectories is off, in which case they are mixed in with the
+// files.  Without any sort keys, entries are ordered by name using the configured
+// collation.
+func orderEntries(dirEntries []entryData, fileEntries []entryData, scmStatus *scm.Status, cwd string) []entryData {
+	keys := lsConfigData.sortKeys
+
+	var entries []entryData
+	if len(keys) == 0 {
+		keys = nameSortKeys
+	} else if !lsConfigData.groupDirectories {
+		entries = append(entries, dirEntries...)
+		entries = append(entries, fileEntries...)
+		sortEntries(entries, keys, scmStatus, cwd)
+		return entries
+	}
+
+	dirs := append([]entryData(nil), dirEntries...)
+	files := append([]entryData(nil), fileEntries...)
+	sortEntries(dirs, keys, scmStatus, cwd)
+	sortEntries(files, keys, scmStatus, cwd)
+
+	if !lsConfigData.fileFirst {
+		entries = append(dirs, files...)
+	} else {
+		entries = append(files, dirs...)
+	}
+
+	return entries
+}
@@ -0,0 +1,147 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// POSIX file systems only carry a subset of the Windows attributes, so the
+// remaining flags are derived from the mode bits and the entry name, keeping
+// the same eight-column layout on every platform.
+func processStats(file string) (string, string) {
+	flags := []string{"-", "-", "-", "-", "-", "-", "-", "-"}
+
+	lfi, err := os.Lstat(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// follow symlinks to find out what they point at, the same way
+	// GetFileAttributes reports the directory bit of a reparse point
+	fi := lfi
+	if lfi.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Stat(file); err == nil {
+			fi = target
+		}
+	}
+
+	if fi.IsDir() {
+		file = fmt.Sprint(file, "/")
+	}
+
+	if fi.Mode().Perm()&0200 == 0 {
+		flags[0] = "r"
+	}
+	base := filepath.Base(strings.TrimSuffix(file, "/"))
+	if strings.HasPrefix(base, ".") && base != "." && base != ".." {
+		flags[2] = "h"
+	}
+	if lfi.Mode()&os.ModeSymlink != 0 {
+		flags[6] = "S"
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && fi.Mode().IsRegular() {
+		// fewer allocated blocks than the size requires means there are holes
+		if st.Blocks*512 < st.Size {
+			flags[7] = "p"
+		}
+	}
+
+	return file, strings.Join(flags, "")
+}
+
+var userNames = map[uint32]string{}
+var groupNames = map[uint32]string{}
+
+func lookupUser(uid uint32) string {
+	name, ok := userNames[uid]
+	if !ok {
+		name = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
+		}
+		userNames[uid] = name
+	}
+	return name
+}
+
+func lookupGroup(gid uint32) string {
+	name, ok := groupNames[gid]
+	if !ok {
+		name = strconv.FormatUint(uint64(gid), 10)
+		if g, err := user.LookupGroupId(name); err == nil {
+			name = g.Name
+		}
+		groupNames[gid] = name
+	}
+	return name
+}
+
+// classic "drwxr-xr-x" rendering, including the setuid, setgid and sticky bits
+func formatMode(mode os.FileMode) string {
+	b := []byte("----------")
+
+	switch {
+	case mode&os.ModeDir != 0:
+		b[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		b[0] = 'l'
+	case mode&os.ModeNamedPipe != 0:
+		b[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		b[0] = 's'
+	case mode&os.ModeCharDevice != 0:
+		b[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		b[0] = 'b'
+	}
+
+	const rwx = "rwxrwxrwx"
+	perm := mode.Perm()
+	for i := 0; i < 9; i++ {
+		if perm&(1<<uint(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+
+	special := func(index int, set bool, lower byte, upper byte) {
+		if set {
+			if b[index] == 'x' {
+				b[index] = lower
+			} else {
+				b[index] = upper
+			}
+		}
+	}
+	special(3, mode&os.ModeSetuid != 0, 's', 'S')
+	special(6, mode&os.ModeSetgid != 0, 's', 'S')
+	special(9, mode&os.ModeSticky != 0, 't', 'T')
+
+	return string(b)
+}
+
+// processPermissions returns the mode string, owner and group of the entry
+// itself (i.e., symlinks are not followed)
+func processPermissions(file string) (string, string, string) {
+	fi, err := os.Lstat(strings.TrimSuffix(file, "/"))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	owner := ""
+	group := ""
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		owner = lookupUser(st.Uid)
+		group = lookupGroup(st.Gid)
+	}
+
+	return formatMode(fi.Mode()), owner, group
+}
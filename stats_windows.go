@@ -0,0 +1,69 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"syscall"
+)
+
+// some attributes aren't defined in syscall, so I've had to define them here
+const FILE_ATTRIBUTE_COMPRESSED uint32 = 2048
+const FILE_ATTRIBUTE_ENCRYPTED uint32 = 16384
+const FILE_ATTRIBUTE_SPARSE_FILE uint32 = 512
+
+func processStats(file string) (string, string) {
+	flags := []string{"-", "-", "-", "-", "-", "-", "-", "-"}
+
+	ptr, err := syscall.UTF16PtrFromString(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// https://golang.hotexamples.com/examples/syscall/-/GetFileAttributes/golang-getfileattributes-function-examples.html
+	attr, err := syscall.GetFileAttributes(ptr)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if attr&syscall.FILE_ATTRIBUTE_DIRECTORY != 0 {
+		file = fmt.Sprint(file, "/")
+	}
+
+	if attr&syscall.FILE_ATTRIBUTE_READONLY != 0 {
+		flags[0] = "r"
+	}
+	if attr&syscall.FILE_ATTRIBUTE_ARCHIVE != 0 {
+		flags[1] = "a"
+	}
+	if attr&syscall.FILE_ATTRIBUTE_HIDDEN != 0 {
+		flags[2] = "h"
+	}
+	if attr&syscall.FILE_ATTRIBUTE_SYSTEM != 0 {
+		flags[3] = "s"
+	}
+	if attr&FILE_ATTRIBUTE_COMPRESSED != 0 {
+		flags[4] = "c"
+	}
+	if attr&FILE_ATTRIBUTE_ENCRYPTED != 0 {
+		flags[5] = "e"
+	}
+	if attr&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0 {
+		flags[6] = "S"
+	}
+	if attr&FILE_ATTRIBUTE_SPARSE_FILE != 0 {
+		flags[7] = "p"
+	}
+
+	return file, strings.Join(flags, "")
+}
+
+// processPermissions has nothing to report on Windows, where access is governed
+// by ACLs instead of mode bits, so the permission, owner and group columns are
+// left out of the listing
+func processPermissions(file string) (string, string, string) {
+	return "", "", ""
+}
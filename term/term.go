@@ -1,50 +1,213 @@
 package term
 
 import (
-	"github.com/nathan-fiscaletti/consolesize-go"
-	"golang.org/x/sys/windows"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
-var dllTerminal *windows.DLL = nil
+// color depths that the terminal can display
+const (
+	DEPTH_NONE = iota
+	DEPTH_16
+	DEPTH_256
+	DEPTH_TRUECOLOR
+)
+
+// color modes selectable by the user (--color)
+const (
+	COLOR_AUTO = iota
+	COLOR_ALWAYS
+	COLOR_NEVER
+)
+
+// keys that ReadKey reports other than as the character they produce
+const (
+	KEY_ERROR   = -1 - iota // there is no keyboard to read from
+	KEY_UNKNOWN             // a key sequence that isn't recognized
+	KEY_UP
+	KEY_DOWN
+	KEY_PAGE_UP
+	KEY_PAGE_DOWN
+)
+
+// Capabilities ... This holds what is known about the terminal that stdout is
+// connected to (if any).
+type Capabilities struct {
+	IsTTY      bool
+	ColorDepth int
+	Hyperlinks bool
+	UTF8       bool
+	Width      int
+	Height     int
+}
 
-// GetDimensions ... Returns the dimensions of the current console window.
+// GetDimensions ... Returns the dimensions of the current console window.  If there
+// is no console attached (e.g., output is redirected), the classic 80x25 is assumed.
+// COLUMNS and LINES in the environment override what the console reports.
 func GetDimensions() (int, int) {
-	cols, rows := consolesize.GetConsoleSize()
+	cols, rows := getConsoleSize()
+	if value, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && value > 0 {
+		cols = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("LINES")); err == nil && value > 0 {
+		rows = value
+	}
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 25
+	}
 	return rows, cols
 }
 
-// EnableColor ... This function uses shared library functions to see if ANSI coloring
-// can be used in the current console.  It will attempt to enable coloring if it isn't
-// already, and will report the results.
-func EnableColor() bool {
-	result := false
+// IsTerminal ... Reports whether the provided file is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f)
+}
 
-	if dllTerminal == nil {
-		dll, err := windows.LoadDLL("terminal.dll")
-		if err == nil {
-			dllTerminal = dll
+// ParseColorMode ... Converts the value of the --color switch ("auto", "always" or
+// "never") into one of the COLOR_* modes.
+func ParseColorMode(mode string) (int, error) {
+	switch strings.ToLower(mode) {
+	case "", "auto":
+		return COLOR_AUTO, nil
+	case "always", "force", "yes":
+		return COLOR_ALWAYS, nil
+	case "never", "none", "no":
+		return COLOR_NEVER, nil
+	}
+	return COLOR_AUTO, fmt.Errorf("invalid color mode '%s' (expected auto, always or never)", mode)
+}
+
+// Detect ... Determines the capabilities of the terminal connected to stdout.  In
+// COLOR_AUTO mode, the NO_COLOR, CLICOLOR_FORCE, COLORTERM and TERM environment
+// variables are honored (https://no-color.org, https://bixense.com/clicolors).
+func Detect(colorMode int) Capabilities {
+	var caps Capabilities
+
+	caps.IsTTY = isTerminal(os.Stdout)
+	caps.Height, caps.Width = GetDimensions()
+
+	termName := os.Getenv("TERM")
+
+	wantColor := false
+	switch colorMode {
+	case COLOR_ALWAYS:
+		wantColor = true
+	case COLOR_NEVER:
+		wantColor = false
+	default:
+		if len(os.Getenv("NO_COLOR")) != 0 {
+			wantColor = false
+		} else if force := os.Getenv("CLICOLOR_FORCE"); len(force) != 0 && force != "0" {
+			wantColor = true
+		} else {
+			wantColor = caps.IsTTY && termName != "dumb"
 		}
 	}
 
-	if dllTerminal != nil {
-		// if we have the DLL, we MUST have the entry points
-		procHasColorSupport := dllTerminal.MustFindProc("HasColorSupport")
-		procIsColorSupportEnabled := dllTerminal.MustFindProc("IsColorSupportEnabled")
-		procEnableColorSupport := dllTerminal.MustFindProc("EnableColorSupport")
-
-		r, _, _ := procHasColorSupport.Call()
-		if r == 1 {
-			r, _, _ := procIsColorSupportEnabled.Call()
-			if r == 0 {
-				enabled, _, _ := procEnableColorSupport.Call(1)
-				if enabled == 1 {
-					result = true
-				}
-			} else {
-				result = true
+	if wantColor {
+		// enabling may fail on consoles that predate ANSI support, but if the
+		// user insists, they get the escape sequences anyway
+		if EnableColor() || colorMode == COLOR_ALWAYS || !caps.IsTTY {
+			caps.ColorDepth = colorDepth(termName)
+		}
+	}
+
+	caps.Hyperlinks = caps.IsTTY && hasHyperlinks(termName)
+	caps.UTF8 = isUTF8()
+
+	return caps
+}
+
+// what the terminal has sent that hasn't been returned as a key yet (a paste, or
+// keys typed faster than they are read)
+var pendingKeys []byte
+
+// nextKey takes the first key off the pending input, converting it into a
+// character or one of the KEY_* codes.  Cursor keys arrive as ANSI escape
+// sequences.
+func nextKey() int {
+	b := pendingKeys
+
+	if b[0] == 27 && len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+		// CSI sequences end at the first byte in the range '@' to '~'
+		n := 3
+		if b[1] == '[' {
+			for n = 2; n < len(b) && (b[n] < '@' || b[n] > '~'); n++ {
 			}
+			n++
+		}
+		if n > len(b) {
+			n = len(b)
+		}
+		pendingKeys = b[n:]
+
+		switch string(b[1:n]) {
+		case "[A", "OA":
+			return KEY_UP
+		case "[B", "OB":
+			return KEY_DOWN
+		case "[5~":
+			return KEY_PAGE_UP
+		case "[6~":
+			return KEY_PAGE_DOWN
 		}
+		return KEY_UNKNOWN
+	}
+
+	r, size := utf8.DecodeRune(b)
+	pendingKeys = b[size:]
+	return int(r)
+}
+
+// the first of LC_ALL, LC_CTYPE and LANG that is set decides the character set
+func localeIsUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value := os.Getenv(name)
+		if len(value) != 0 {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return false
+}
+
+func colorDepth(termName string) int {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return DEPTH_TRUECOLOR
+	}
+	if strings.Contains(termName, "256color") {
+		return DEPTH_256
+	}
+	if len(os.Getenv("WT_SESSION")) != 0 {
+		// Windows Terminal
+		return DEPTH_TRUECOLOR
+	}
+	return DEPTH_16
+}
+
+// OSC 8 hyperlinks are supported by a growing number of terminals, but there is
+// no way to ask, so known emulators are recognized from their environment
+func hasHyperlinks(termName string) bool {
+	if termName == "dumb" || termName == "linux" || strings.HasPrefix(termName, "screen") {
+		return false
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty":
+		return true
+	}
+
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
 	}
 
-	return result
+	return len(os.Getenv("WT_SESSION")) != 0 || len(os.Getenv("KITTY_WINDOW_ID")) != 0 ||
+		strings.HasPrefix(termName, "xterm-kitty") || termName == "foot" || termName == "alacritty"
 }
@@ -0,0 +1,100 @@
+//go:build !windows
+// +build !windows
+
+package term
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func getConsoleSize() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// OpenTerminal ... Opens the controlling terminal for reading and writing, so that
+// it can be drawn on while stdout is being captured (e.g., by a shell's command
+// substitution).
+func OpenTerminal() (*os.File, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// TerminalSize ... Returns the rows and columns of the terminal the file is
+// connected to, or zeroes if it isn't one.
+func TerminalSize(f *os.File) (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0
+	}
+	return int(ws.Row), int(ws.Col)
+}
+
+func isUTF8() bool {
+	return localeIsUTF8()
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), ioctlReadTermios)
+	return err == nil
+}
+
+// EnableColor ... POSIX terminals interpret ANSI sequences natively, so there is
+// nothing to switch on.  Coloring is reported as available as long as stdout is a
+// terminal that isn't declared "dumb".
+func EnableColor() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return isTerminal(os.Stdout)
+}
+
+// ReadKey ... Waits for a keypress and returns the character it produces, or one of
+// the KEY_* codes for keys that don't produce one.  The keyboard is read from
+// the controlling terminal if stdin has been redirected.
+func ReadKey() int {
+	if len(pendingKeys) != 0 {
+		return nextKey()
+	}
+
+	tty := os.Stdin
+	if !isTerminal(tty) {
+		f, err := os.Open("/dev/tty")
+		if err != nil {
+			return KEY_ERROR
+		}
+		defer f.Close()
+		tty = f
+	}
+	fd := int(tty.Fd())
+
+	saved, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return KEY_ERROR
+	}
+
+	raw := *saved
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Iflag &^= unix.IXON | unix.ICRNL
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw); err != nil {
+		return KEY_ERROR
+	}
+	defer unix.IoctlSetTermios(fd, ioctlWriteTermios, saved)
+
+	// the bytes of an escape sequence arrive together
+	buffer := make([]byte, 64)
+	n, err := tty.Read(buffer)
+	if err != nil || n == 0 {
+		return KEY_ERROR
+	}
+	pendingKeys = buffer[:n]
+
+	return nextKey()
+}
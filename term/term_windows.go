@@ -0,0 +1,117 @@
+//go:build windows
+// +build windows
+
+package term
+
+import (
+	"os"
+
+	"github.com/nathan-fiscaletti/consolesize-go"
+	"golang.org/x/sys/windows"
+)
+
+var procGetch *windows.Proc = nil
+var procGetConsoleOutputCP = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetConsoleOutputCP")
+
+const CP_UTF8 = 65001
+
+func getConsoleSize() (int, int) {
+	return consolesize.GetConsoleSize()
+}
+
+// OpenTerminal ... Opens the console's screen buffer, so that it can be drawn on
+// while stdout is being captured (e.g., by a shell's command substitution).
+// Virtual terminal processing is switched on for it, as it is for stdout.
+func OpenTerminal() (*os.File, error) {
+	f, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err == nil {
+		windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	}
+
+	return f, nil
+}
+
+// TerminalSize ... Returns the rows and columns of the console window the file is
+// connected to, or zeroes if it isn't one.
+func TerminalSize(f *os.File) (int, int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0, 0
+	}
+	return int(info.Window.Bottom-info.Window.Top) + 1, int(info.Window.Right-info.Window.Left) + 1
+}
+
+// the console's output code page decides what it can display, although Windows
+// Terminal (and shells like MSYS2 that set a locale) handle UTF-8 regardless
+func isUTF8() bool {
+	if len(os.Getenv("WT_SESSION")) != 0 || localeIsUTF8() {
+		return true
+	}
+
+	cp, _, _ := procGetConsoleOutputCP.Call()
+	return cp == CP_UTF8
+}
+
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(f.Fd()), &mode) == nil
+}
+
+// EnableColor ... Checks to see if ANSI coloring can be used in the current console.
+// It will attempt to enable virtual terminal processing if it isn't already, and
+// will report the results.  Consoles that predate Windows 10 (build 10586) will
+// refuse the request.
+func EnableColor() bool {
+	handle := windows.Handle(os.Stdout.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+// waits for a single keypress from the console and returns it, without echoing
+// it or waiting for Enter
+func getch() int {
+	if procGetch == nil {
+		dllMsvcrt := windows.MustLoadDLL("msvcrt.dll")
+		procGetch = dllMsvcrt.MustFindProc("_getch")
+	}
+
+	result, _, _ := procGetch.Call()
+	return int(result)
+}
+
+// ReadKey ... Waits for a keypress and returns the character it produces, or one of
+// the KEY_* codes for keys that don't produce one.  _getch reports those keys as
+// a 0 or 0xE0 followed by their scan code.
+func ReadKey() int {
+	key := getch()
+	if key != 0 && key != 0xE0 {
+		return key
+	}
+
+	switch getch() {
+	case 72:
+		return KEY_UP
+	case 80:
+		return KEY_DOWN
+	case 73:
+		return KEY_PAGE_UP
+	case 81:
+		return KEY_PAGE_DOWN
+	}
+	return KEY_UNKNOWN
+}
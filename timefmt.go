@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// the timestamp layout ls has always used
+const classicTimeLayout = "01/02/06 15:04:05"
+
+// the width of a relative timestamp ("11mo ago")
+const relativeTimeWidth = 8
+
+// timeFormatter ... Renders timestamps in the configured style, zone and
+// precision.  Every timestamp displayed goes through it.
+type timeFormatter struct {
+	layout   string
+	relative bool
+	location *time.Location
+	width    int
+}
+
+// the formatter for the listing, set up by newTimeFormatter() at startup
+var timeFormat = timeFormatter{layout: classicTimeLayout, location: time.Local, width: len(classicTimeLayout)}
+
+// the layout of the "locale" style, picked by the conventions of the locale's
+// region (or language, where the region doesn't settle it)
+func localeTimeLayout(tag language.Tag) string {
+	if tag == language.Und {
+		return "2006-01-02 15:04:05"
+	}
+
+	region, _ := tag.Region()
+	switch region.String() {
+	case "US", "PH":
+		return "01/02/2006 03:04:05 PM"
+	case "CA":
+		return "2006-01-02 15:04:05"
+	}
+
+	base, _ := tag.Base()
+	switch base.String() {
+	case "en", "fr", "es", "it", "pt", "el", "ca", "ga", "cy", "vi", "id", "ms":
+		return "02/01/2006 15:04:05"
+	case "nl":
+		return "02-01-2006 15:04:05"
+	case "de", "ru", "pl", "cs", "sk", "fi", "nb", "nn", "no", "da", "tr", "uk", "ro", "bg", "sr", "hr", "sl", "et", "lv", "is":
+		return "02.01.2006 15:04:05"
+	case "hu":
+		return "2006. 01. 02. 15:04:05"
+	}
+	return "2006-01-02 15:04:05"
+}
+
+// withPrecision adds the requested number of fractional digits to the seconds
+// of the layout (if it has seconds, and doesn't already show fractions)
+func withPrecision(layout string, precision int) string {
+	if precision <= 0 || strings.Contains(layout, "05.0") || strings.Contains(layout, "05.9") {
+		return layout
+	}
+	if precision > 9 {
+		precision = 9
+	}
+	return strings.Replace(layout, "05", "05."+strings.Repeat("0", precision), 1)
+}
+
+// newTimeFormatter ... Builds the timestamp formatter for a style, which is one of
+//
+//	classic   "01/02/06 15:04:05" (the default)
+//	iso       "2006-01-02 15:04"
+//	long-iso  "2006-01-02 15:04:05"
+//	full-iso  RFC 3339, with the zone offset
+//	locale    the date order of LC_TIME (or the configured locale)
+//	relative  the age of the timestamp, e.g., "3h ago"
+//
+// or a custom Go layout written with the reference time (Mon Jan 2 15:04:05
+// MST 2006).  The zone is "" or "local" for local time, "UTC", or an IANA
+// name like "Europe/Berlin".  precision adds up to 9 digits of sub-second
+// precision.
+func newTimeFormatter(style string, zone string, precision int, locale string) (timeFormatter, error) {
+	formatter := timeFormatter{location: time.Local}
+
+	switch strings.ToLower(zone) {
+	case "", "local":
+	case "utc":
+		formatter.location = time.UTC
+	default:
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return formatter, fmt.Errorf("unknown time zone '%s'", zone)
+		}
+		formatter.location = location
+	}
+
+	switch strings.ToLower(style) {
+	case "", "classic", "default":
+		formatter.layout = classicTimeLayout
+	case "iso":
+		formatter.layout = "2006-01-02 15:04"
+	case "long-iso":
+		formatter.layout = "2006-01-02 15:04:05"
+	case "full-iso", "rfc3339":
+		formatter.layout = "2006-01-02T15:04:05Z07:00"
+	case "locale":
+		formatter.layout = localeTimeLayout(localeTag(locale, "LC_TIME"))
+	case "relative":
+		formatter.relative = true
+		formatter.width = relativeTimeWidth
+		return formatter, nil
+	default:
+		// anything that doesn't change when formatted isn't a layout
+		if (time.Time{}).Format(style) == style {
+			return formatter, fmt.Errorf("unknown time format '%s' (expected classic, iso, long-iso, full-iso, locale, relative or a Go layout)", style)
+		}
+		formatter.layout = style
+	}
+
+	formatter.layout = withPrecision(formatter.layout, precision)
+
+	// month and day names vary in length, so measure the widest
+	for month := time.January; month <= time.December; month++ {
+		for day := 24; day <= 30; day++ {
+			sample := time.Date(2006, month, day, 23, 59, 59, 999999999, formatter.location)
+			if width := displayWidth(sample.Format(formatter.layout)); width > formatter.width {
+				formatter.width = width
+			}
+		}
+	}
+
+	return formatter, nil
+}
+
+// relativeTime renders the age of the timestamp in its largest whole unit
+func relativeTime(t time.Time, now time.Time) string {
+	age := now.Sub(t)
+	future := age < 0
+	if future {
+		age = -age
+	}
+
+	var amount int64
+	var unit string
+	switch {
+	case age < time.Minute:
+		if age < 10*time.Second {
+			return "just now"
+		}
+		amount, unit = int64(age/time.Second), "s"
+	case age < time.Hour:
+		amount, unit = int64(age/time.Minute), "m"
+	case age < 24*time.Hour:
+		amount, unit = int64(age/time.Hour), "h"
+	case age < 7*24*time.Hour:
+		amount, unit = int64(age/(24*time.Hour)), "d"
+	case age < 30*24*time.Hour:
+		amount, unit = int64(age/(7*24*time.Hour)), "w"
+	case age < 365*24*time.Hour:
+		amount, unit = int64(age/(30*24*time.Hour)), "mo"
+	default:
+		amount, unit = int64(age/(365*24*time.Hour)), "y"
+	}
+
+	if future {
+		return fmt.Sprintf("in %d%s", amount, unit)
+	}
+	return fmt.Sprintf("%d%s ago", amount, unit)
+}
+
+// format ... Renders the timestamp, padded to the formatter's width.  Zero times
+// (timestamps the platform doesn't provide) are rendered blank.
+func (f *timeFormatter) format(t time.Time) string {
+	if t.IsZero() {
+		return strings.Repeat(" ", f.width)
+	}
+
+	var s string
+	if f.relative {
+		s = relativeTime(t, time.Now())
+		if width := displayWidth(s); width < f.width {
+			s = strings.Repeat(" ", f.width-width) + s
+		}
+		return s
+	}
+
+	s = t.In(f.location).Format(f.layout)
+	if width := displayWidth(s); width < f.width {
+		s += strings.Repeat(" ", f.width-width)
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the timestamps that can be displayed and sorted on
+const (
+	TIME_MODIFIED = "mtime"
+	TIME_ACCESSED = "atime"
+	TIME_CHANGED  = "ctime"
+	TIME_BIRTH    = "btime"
+)
+
+// parseTimeField ... Converts the name of a timestamp (or one of its aliases) into
+// one of the TIME_* fields.
+func parseTimeField(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "mtime", "modified", "modification", "write":
+		return TIME_MODIFIED, nil
+	case "atime", "accessed", "access", "use":
+		return TIME_ACCESSED, nil
+	case "ctime", "changed", "change", "status":
+		return TIME_CHANGED, nil
+	case "btime", "birth", "created", "creation":
+		return TIME_BIRTH, nil
+	}
+	return "", fmt.Errorf("unknown timestamp '%s' (expected mtime, atime, ctime or btime)", name)
+}
+
+// parseTimeFields ... Converts a comma-separated list of timestamps into TIME_* fields.
+func parseTimeFields(spec string) ([]string, error) {
+	var fields []string
+	for _, item := range strings.Split(spec, ",") {
+		if len(strings.TrimSpace(item)) == 0 {
+			continue
+		}
+		field, err := parseTimeField(item)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// entryTime ... Returns the requested timestamp of the entry.  The result is the
+// zero time if it isn't available.
+func entryTime(entry *entryData, field string) time.Time {
+	switch field {
+	case TIME_ACCESSED:
+		return entry.atime
+	case TIME_CHANGED:
+		return entry.ctime
+	case TIME_BIRTH:
+		return entry.btime
+	}
+	return entry.modtime
+}
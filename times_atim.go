@@ -0,0 +1,23 @@
+//go:build openbsd || dragonfly
+// +build openbsd dragonfly
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// processTimes returns the access and status change times of the file; birth
+// times aren't reported on this platform
+func processTimes(file string, fi os.FileInfo) (time.Time, time.Time, time.Time) {
+	var atime, ctime time.Time
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		atime = time.Unix(st.Atim.Unix())
+		ctime = time.Unix(st.Ctim.Unix())
+	}
+
+	return atime, ctime, time.Time{}
+}
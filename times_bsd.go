@@ -0,0 +1,27 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// processTimes returns the access, status change and birth times of the file.
+// File systems that don't record birth times report them as zero (or, on
+// FreeBSD, as -1).
+func processTimes(file string, fi os.FileInfo) (time.Time, time.Time, time.Time) {
+	var atime, ctime, btime time.Time
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		atime = time.Unix(st.Atimespec.Unix())
+		ctime = time.Unix(st.Ctimespec.Unix())
+		if st.Birthtimespec.Sec > 0 {
+			btime = time.Unix(st.Birthtimespec.Unix())
+		}
+	}
+
+	return atime, ctime, btime
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func timespecToTime(ts unix.StatxTimestamp) time.Time {
+	return time.Unix(ts.Sec, int64(ts.Nsec))
+}
+
+// processTimes returns the access, status change and birth times of the file.
+// Birth times are only available through statx (Linux 4.11+), and only on
+// file systems that record them.
+func processTimes(file string, fi os.FileInfo) (time.Time, time.Time, time.Time) {
+	var atime, ctime, btime time.Time
+
+	var stx unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, file, 0, unix.STATX_ATIME|unix.STATX_CTIME|unix.STATX_BTIME, &stx)
+	if err == nil {
+		if stx.Mask&unix.STATX_ATIME != 0 {
+			atime = timespecToTime(stx.Atime)
+		}
+		if stx.Mask&unix.STATX_CTIME != 0 {
+			ctime = timespecToTime(stx.Ctime)
+		}
+		if stx.Mask&unix.STATX_BTIME != 0 {
+			btime = timespecToTime(stx.Btime)
+		}
+	} else if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		// kernels that predate statx
+		atime = time.Unix(st.Atim.Unix())
+		ctime = time.Unix(st.Ctim.Unix())
+	}
+
+	return atime, ctime, btime
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !windows && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!windows,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// processTimes only knows about modification times on this platform
+func processTimes(file string, fi os.FileInfo) (time.Time, time.Time, time.Time) {
+	return time.Time{}, time.Time{}, time.Time{}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// processTimes returns the access, status change and creation times of the
+// file.  NTFS does track a change time, but it isn't exposed through the file
+// attribute data, so it is reported as unavailable.
+func processTimes(file string, fi os.FileInfo) (time.Time, time.Time, time.Time) {
+	var atime, ctime, btime time.Time
+
+	if data, ok := fi.Sys().(*syscall.Win32FileAttributeData); ok {
+		atime = time.Unix(0, data.LastAccessTime.Nanoseconds())
+		btime = time.Unix(0, data.CreationTime.Nanoseconds())
+	}
+
+	return atime, ctime, btime
+}
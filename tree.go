@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b0bh00d/ls/scm"
+)
+
+// the guides drawn in front of each node of the tree
+type treeGuides struct {
+	branch string
+	last   string
+	pipe   string
+	blank  string
+}
+
+var utf8Guides = treeGuides{"├── ", "└── ", "│   ", "    "}
+
+// for terminals that can't display box-drawing characters
+var asciiGuides = treeGuides{"|-- ", "`-- ", "|   ", "    "}
+
+// gathers the entries of the current directory for the tree: all of the
+// subdirectories, and the files that match the patterns
+func treeEntries(patterns []string) ([]entryData, []entryData) {
+	var dirEntries []entryData
+	var fileEntries []entryData
+
+	files, err := filepath.Glob("*")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, file := range files {
+		entry := processFile(file)
+
+		if lsConfigData.hideHidden && entry.stats[2] == 'h' {
+			continue
+		}
+		if lsConfigData.hideSystem && entry.stats[3] == 's' {
+			continue
+		}
+
+		if entry.isDir {
+			dirEntries = append(dirEntries, entry)
+			continue
+		}
+
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(convertToCI(pattern), file); matched {
+				fileEntries = append(fileEntries, entry)
+				break
+			}
+		}
+	}
+
+	return dirEntries, fileEntries
+}
+
+// renders a single node of the tree: the SCM codes, the guides, the entry's
+// name and any metadata it has.  Metadata that has to continue on lines of its
+// own is drawn under the node, behind the continuing guides.
+func treeLine(entry entryData, cwd string, prefix string, childPrefix string, scmStatus *scm.Status, scmWidth int) []string {
+	scmLine := ""
+	scmRename := ""
+	if scmWidth != 0 {
+		codes := ""
+		scmEntry, ok := scmStatus.Entries[entry.file]
+		if ok {
+			codes = scmEntry.Codes
+			e, ok := scmStatus.Deleted[entry.file]
+			if ok {
+				scmRename = e.Original
+			}
+		}
+		if len(codes) < scmWidth {
+			codes += strings.Repeat(" ", scmWidth-len(codes))
+		}
+		scmLine = colorizeCodes(codes) + " "
+		scmWidth++
+	}
+
+	used := scmWidth + displayWidth(prefix)
+	line := elideName(entry.file, scmRename, termCaps.Width-used-1)
+
+	indent := strings.Repeat(" ", scmWidth) + childPrefix + "  "
+
+	metadata, rendered, continued := placeMetadata(entryMetadata(entry, cwd), termCaps.Width-used-displayWidth(line)-5, indent)
+	metaDataLength := displayWidth(metadata)
+	if metaDataLength != 0 {
+		colsLeft := termCaps.Width - used - displayWidth(line) - metaDataLength - 4
+		line += " "
+		line += strings.Repeat("-", colsLeft)
+		line += "> "
+	}
+
+	if entry.isDir {
+		line = lsConfigData.coloring["directories"].Sprint(line)
+	} else if fileColor := entryColor(entry.file); fileColor != nil {
+		line = fileColor.Sprint(line)
+	}
+
+	line = fmt.Sprint(scmLine, prefix, line)
+
+	if metaDataLength != 0 {
+		line += rendered
+	}
+
+	return append([]string{line}, continued...)
+}
+
+// walks the directory, printing a node for each entry and descending into
+// each subdirectory in turn.  Returns the number of directories and files
+// displayed.
+func walkTree(dir string, patterns []string, prefix string, depth int, guides treeGuides, scmWidth int, visited *visitedDirs) (int, int) {
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 0, 0
+	}
+
+	scmStatus := scm.GetScmStatus(dir)
+
+	dirEntries, fileEntries := treeEntries(patterns)
+
+	entries := orderEntries(dirEntries, fileEntries, &scmStatus, dir)
+
+	dirs := len(dirEntries)
+	files := len(fileEntries)
+
+	for i, entry := range entries {
+		guide := guides.branch
+		childPrefix := prefix + guides.pipe
+		if i == len(entries)-1 {
+			guide = guides.last
+			childPrefix = prefix + guides.blank
+		}
+
+		for _, line := range treeLine(entry, dir, prefix+guide, childPrefix, &scmStatus, scmWidth) {
+			printLine(line)
+		}
+
+		if !entry.isDir {
+			continue
+		}
+		if lsConfigData.maxDepth > 0 && depth >= lsConfigData.maxDepth {
+			continue
+		}
+		if entry.stats[6] == 'S' && !lsConfigData.followLinks {
+			continue
+		}
+
+		subdir := filepath.Join(dir, strings.TrimSuffix(entry.file, "/"))
+		if visited.seen(subdir) {
+			continue
+		}
+
+		d, f := walkTree(subdir, patterns, childPrefix, depth+1, guides, scmWidth, visited)
+		dirs += d
+		files += f
+
+		if err := os.Chdir(dir); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return dirs, files
+}
+
+// renderTree ... Displays the directory, and everything beneath it, as a tree.
+func renderTree(root string, patterns []string) {
+	guides := utf8Guides
+	if lsConfigData.treeASCII || !termCaps.UTF8 {
+		guides = asciiGuides
+	}
+
+	if err := os.Chdir(root); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	// the SCM codes column has to be the same width for every folder in the
+	// tree (unlike a flat listing, where each folder has its own).  The status
+	// of the root covers everything beneath it, so its widest codes are the
+	// widest of the tree.
+	scmWidth := scm.GetScmStatus(root).MaxWidth
+
+	var visited visitedDirs
+	visited.seen(root)
+
+	rootLine := lsConfigData.coloring["directories"].Sprint(root)
+	if scmWidth != 0 {
+		rootLine = strings.Repeat(" ", scmWidth+1) + rootLine
+	}
+	printLine(rootLine)
+
+	dirs, files := walkTree(root, patterns, "", 1, guides, scmWidth, &visited)
+
+	dirData := fmt.Sprintf("%d director", dirs)
+	if dirs == 1 {
+		dirData += "y"
+	} else {
+		dirData += "ies"
+	}
+	fileData := fmt.Sprintf("%d file", files)
+	if files != 1 {
+		fileData += "s"
+	}
+
+	printLine("")
+	printLine(fmt.Sprintf("%s, %s", dirData, fileData))
+}
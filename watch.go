@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the kinds of change that watch mode highlights
+const (
+	WATCH_ADDED    = "added"
+	WATCH_MODIFIED = "modified"
+	WATCH_REMOVED  = "removed"
+)
+
+// how long the folder has to be quiet before it is listed again, and the
+// longest a burst of changes can hold the listing back
+const watchDebounce = 250 * time.Millisecond
+const watchMaxDelay = 2 * time.Second
+
+// how often the folder is checked where it can't be watched for changes
+const watchPollInterval = time.Second
+
+// what a change to an entry is recognized by
+type watchedEntry struct {
+	size    uint64
+	modtime time.Time
+	isDir   bool
+}
+
+type watchChange struct {
+	kind  string
+	until time.Time
+}
+
+// watchState ... The entries of the previous listing, and the changes that are
+// being highlighted (until when).
+type watchState struct {
+	seen    map[string]watchedEntry
+	changes map[string]watchChange
+}
+
+// the state of watch mode, if the folder is being watched
+var watching *watchState
+
+// update compares the entries with those of the previous listing, noting the
+// entries that have been added, modified or removed since, and forgetting the
+// changes that have been highlighted long enough
+func (w *watchState) update(entries []entryData) {
+	now := time.Now()
+	until := now.Add(time.Duration(lsConfigData.watchHighlight) * time.Second)
+
+	current := make(map[string]watchedEntry)
+	for _, entry := range entries {
+		current[entry.file] = watchedEntry{entry.size, entry.modtime, entry.isDir}
+	}
+
+	// the first listing has nothing to compare with
+	if w.seen != nil {
+		for name, entry := range current {
+			previous, ok := w.seen[name]
+			if !ok {
+				w.changes[name] = watchChange{WATCH_ADDED, until}
+			} else if previous.size != entry.size || previous.isDir != entry.isDir || !previous.modtime.Equal(entry.modtime) {
+				w.changes[name] = watchChange{WATCH_MODIFIED, until}
+			}
+		}
+		for name := range w.seen {
+			if _, ok := current[name]; !ok {
+				w.changes[name] = watchChange{WATCH_REMOVED, until}
+			}
+		}
+	}
+
+	for name, change := range w.changes {
+		if !change.until.After(now) {
+			delete(w.changes, name)
+		}
+	}
+
+	w.seen = current
+}
+
+// nextExpiry returns when the next highlight runs out, if any are showing
+func (w *watchState) nextExpiry() (time.Time, bool) {
+	var next time.Time
+	for _, change := range w.changes {
+		if next.IsZero() || change.until.Before(next) {
+			next = change.until
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// watchHighlight ... Returns the color key an entry is highlighted with, if it has
+// changed recently.
+func watchHighlight(file string) string {
+	if watching == nil {
+		return ""
+	}
+	if change, ok := watching.changes[file]; ok && change.kind != WATCH_REMOVED {
+		return "watch." + change.kind
+	}
+	return ""
+}
+
+// watchRemovedLines ... Renders the entries that have recently been removed, with
+// their names starting at the indent.
+func watchRemovedLines(indent int) []string {
+	if watching == nil {
+		return nil
+	}
+
+	var names []string
+	for name, change := range watching.changes {
+		if change.kind == WATCH_REMOVED {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i int, j int) bool {
+		return compareNames(names[i], names[j]) < 0
+	})
+
+	var lines []string
+	for _, name := range names {
+		name = elideName(name, "", termCaps.Width-indent-1)
+		lines = append(lines, strings.Repeat(" ", indent)+lsConfigData.coloring["watch."+WATCH_REMOVED].Sprint(name))
+	}
+	return lines
+}
+
+// notify signals that the folder has changed, unless a signal is already
+// waiting to be picked up
+func notify(events chan<- struct{}) {
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+}
+
+// dirSignature sums up the names, sizes and timestamps of the folder's entries
+func dirSignature(dir string) uint64 {
+	h := fnv.New64a()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprint(h, err)
+		return h.Sum64()
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", entry.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return h.Sum64()
+}
+
+// pollDirectory checks the folder for changes every so often, for platforms
+// (and file systems) that can't report them
+func pollDirectory(dir string, events chan<- struct{}) {
+	last := dirSignature(dir)
+	for {
+		time.Sleep(watchPollInterval)
+		if signature := dirSignature(dir); signature != last {
+			last = signature
+			notify(events)
+		}
+	}
+}
+
+// debounce waits for a burst of changes to settle
+func debounce(events <-chan struct{}) {
+	deadline := time.After(watchMaxDelay)
+	for {
+		select {
+		case <-events:
+		case <-time.After(watchDebounce):
+			return
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// watchDirectory ... Renders the listing of the folder, and renders it again each
+// time its entries change (or a highlight runs out), until interrupted.
+func watchDirectory(dir string, render func()) {
+	watching = &watchState{changes: make(map[string]watchChange)}
+
+	events := make(chan struct{}, 1)
+	if !startNotifier(dir, events) {
+		go pollDirectory(dir, events)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	for {
+		// start each listing at the top of a clear display
+		fmt.Print("\x1b[H\x1b[2J")
+		render()
+
+		var expiry <-chan time.Time
+		if next, ok := watching.nextExpiry(); ok {
+			expiry = time.After(time.Until(next))
+		}
+
+		select {
+		case <-events:
+			debounce(events)
+		case <-expiry:
+		case <-interrupts:
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// the changes to the folder's entries that inotify reports
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// startNotifier has inotify report changes to the folder.  If inotify stops
+// working (or can't be used at all), the folder is polled instead.
+func startNotifier(dir string, events chan<- struct{}) bool {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return false
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		unix.Close(fd)
+		return false
+	}
+
+	go func() {
+		defer unix.Close(fd)
+
+		// the events themselves don't matter, only that there were some
+		buffer := make([]byte, 64*1024)
+		for {
+			n, err := unix.Read(fd, buffer)
+			if err == unix.EINTR {
+				continue
+			}
+			if err != nil || n <= 0 {
+				pollDirectory(dir, events)
+				return
+			}
+			notify(events)
+		}
+	}()
+
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// startNotifier reports that changes to the folder can't be watched for on this
+// platform, so it has to be polled
+func startNotifier(dir string, events chan<- struct{}) bool {
+	return false
+}
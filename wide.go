@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/b0bh00d/ls/scm"
+)
+
+// the gap between the columns of a wide listing
+const wideGutter = 2
+
+// builds the cell for a single entry: its SCM codes (if the folder is managed)
+// and its name, colored by type or extension.  Returns the cell along with its
+// width on the display.
+func wideCell(entry entryData, scmStatus *scm.Status) (string, int) {
+	scmLine := ""
+	width := 0
+	if len(scmStatus.Entries) != 0 || len(scmStatus.Deleted) != 0 {
+		codes := ""
+		scmEntry, ok := scmStatus.Entries[entry.file]
+		if ok {
+			codes = scmEntry.Codes
+		}
+		codes += strings.Repeat(" ", scmStatus.MaxWidth-len(codes))
+		scmLine = colorizeCodes(codes) + " "
+		width = scmStatus.MaxWidth + 1
+	}
+
+	name := elideName(entry.file, "", termCaps.Width-width)
+	width += displayWidth(name)
+
+	if entry.isDir {
+		name = lsConfigData.coloring["directories"].Sprint(name)
+	} else if fileColor := entryColor(entry.file); fileColor != nil {
+		name = fileColor.Sprint(name)
+	}
+	if highlight := changeHighlight(entry.file); len(highlight) != 0 {
+		name = lsConfigData.coloring[highlight].Sprint(name)
+	}
+
+	return scmLine + name, width
+}
+
+// finds the largest number of columns that the cells can be packed into
+// without exceeding the display width.  Returns the number of rows and the
+// width of each column.
+func wideLayout(widths []int, across bool) (int, []int) {
+	count := len(widths)
+
+	// leave the last column of the display alone, because some consoles
+	// wrap as soon as it is written to
+	available := termCaps.Width - 1
+
+	for columns := count; columns > 1; columns-- {
+		rows := (count + columns - 1) / columns
+		if !across {
+			// column-major layouts can end up with fewer columns than asked for
+			columns = (count + rows - 1) / rows
+		}
+
+		columnWidths := make([]int, columns)
+		for i, w := range widths {
+			c := i / rows
+			if across {
+				c = i % columns
+			}
+			if w > columnWidths[c] {
+				columnWidths[c] = w
+			}
+		}
+
+		total := wideGutter * (columns - 1)
+		for _, w := range columnWidths {
+			total += w
+		}
+
+		if total <= available {
+			return rows, columnWidths
+		}
+	}
+
+	maxWidth := 0
+	for _, w := range widths {
+		if w > maxWidth {
+			maxWidth = w
+		}
+	}
+	return count, []int{maxWidth}
+}
+
+// wideLines ... Packs the names of the entries into as many columns as the
+// display allows (like "ls -C" or "dir /w").  Entries run down each column
+// unless lsConfigData.wideAcross is set, in which case they run across each row.
+func wideLines(entries []entryData, scmStatus *scm.Status) []string {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cells := make([]string, len(entries))
+	widths := make([]int, len(entries))
+	for i := range entries {
+		cells[i], widths[i] = wideCell(entries[i], scmStatus)
+	}
+
+	across := lsConfigData.wideAcross
+	rows, columnWidths := wideLayout(widths, across)
+	columns := len(columnWidths)
+
+	lines := make([]string, 0, rows)
+	for r := 0; r < rows; r++ {
+		line := ""
+		for c := 0; c < columns; c++ {
+			i := c*rows + r
+			if across {
+				i = r*columns + c
+			}
+			if i >= len(cells) {
+				break
+			}
+
+			// pad to the column's width unless this is the last cell on the line
+			next := (c+1)*rows + r
+			if across {
+				next = i + 1
+			}
+			line += cells[i]
+			if c < columns-1 && next < len(cells) {
+				line += strings.Repeat(" ", columnWidths[c]-widths[i]+wideGutter)
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}